@@ -0,0 +1,150 @@
+// Package analysis annotates chess games with the help of an engine.
+package analysis
+
+import (
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/pgn"
+	"time"
+)
+
+// Thresholds holds the centipawn losses, compared to the engine's best move,
+// from which a move is annotated as an inaccuracy ($6, ?!), a mistake ($2, ?)
+// or a blunder ($4, ??).
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+// DefaultThresholds are the thresholds used by Annotate.
+var DefaultThresholds = Thresholds{Inaccuracy: 50, Mistake: 100, Blunder: 300}
+
+// Annotate analyses the main line of the game with DefaultThresholds, see
+// Thresholds.Annotate.
+func Annotate(g *pgn.Game, e engine.Engine, d time.Duration) error {
+	return DefaultThresholds.Annotate(g, e, d)
+}
+
+// Annotate analyses the main line of the game, searching every position for
+// duration d. The evaluation after each move is embedded in the move's comment
+// as [%eval ...], and moves that lose at least the threshold amount of
+// centipawns compared to the evaluation before the move are marked with the
+// corresponding NAG. Positions without legal moves are not searched.
+func (t Thresholds) Annotate(g *pgn.Game, e engine.Engine, d time.Duration) error {
+	prev, err := evaluate(g.Root.Board, e, d)
+	if err != nil {
+		return err
+	}
+	for _, node := range g.MainLine() {
+		cur, err := evaluate(node.Board, e, d)
+		if err != nil {
+			return err
+		}
+		if cur.Pv != nil {
+			node.SetEval(cur.Score, cur.mate())
+		}
+		if prev.ok() && cur.ok() {
+			loss := prev.cp() - cur.cp()
+			if node.Parent.Board.SideToMove == chess.Black {
+				loss = -loss
+			}
+			if nag := t.nag(loss); nag != 0 {
+				node.AddNag(nag)
+			}
+		}
+		prev = cur
+	}
+	return nil
+}
+
+// AddPv adds the moves of an engine's principal variation, searched in the
+// position after node n, to the game tree. The moves become a variation of
+// the move following n or, if n is the last move of its line, the
+// continuation of the line. The score is embedded as [%eval ...] in the
+// comment of the first move, which is returned. AddPv returns nil and leaves
+// the game alone if pv has no moves.
+func AddPv(n *pgn.Node, pv *engine.Pv) *pgn.Node {
+	if len(pv.Moves) == 0 {
+		return nil
+	}
+	var first *pgn.Node
+	if n.Next == nil {
+		first = n.Insert(pv.Moves[0])
+	} else {
+		first = n.Next.NewVariation().Insert(pv.Moves[0])
+	}
+	mate, _ := pv.MateIn()
+	first.SetEval(pv.Score, mate)
+	last := first
+	for _, m := range pv.Moves[1:] {
+		last = last.Insert(m)
+	}
+	return first
+}
+
+// nag returns the NAG for a move losing loss centipawns, or 0 for a good move.
+func (t Thresholds) nag(loss int) pgn.Nag {
+	switch {
+	case loss >= t.Blunder:
+		return 4
+	case loss >= t.Mistake:
+		return 2
+	case loss >= t.Inaccuracy:
+		return 6
+	}
+	return 0
+}
+
+// score is the evaluation of a position. Either Pv is the engine's principal
+// variation, or final is set for positions without legal moves.
+type score struct {
+	*engine.Pv
+	final bool
+	cp0   int // value of a final position
+}
+
+func (s score) ok() bool { return s.Pv != nil || s.final }
+
+// cp returns the score in centipawns for White.
+func (s score) cp() int {
+	if s.final {
+		return s.cp0
+	}
+	return s.WhiteScore()
+}
+
+// mate returns the mate distance of the score for pgn.Node.SetEval.
+func (s score) mate() int {
+	mate, _ := s.MateIn()
+	return mate
+}
+
+// evaluate returns the engine's evaluation of the position, taken from the last
+// reported (first-ranked) principal variation. The zero score is returned if
+// the engine does not report one.
+func evaluate(b *chess.Board, e engine.Engine, d time.Duration) (score, error) {
+	if check, mate := b.IsCheckOrMate(); mate {
+		s := score{final: true}
+		if check {
+			s.cp0 = engine.MateScore
+			if b.SideToMove == chess.White {
+				s.cp0 = -engine.MateScore
+			}
+		}
+		return s, nil
+	}
+	e.SetPosition(b)
+	var s score
+	for info := range e.SearchTime(d) {
+		if err := info.Err(); err != nil {
+			return score{}, err
+		}
+		// a bound without moves does not replace an exact line
+		pv := info.Pv()
+		if pv != nil && pv.Rank == 0 && (s.Pv == nil || len(pv.Moves) > 0 || !pv.Upperbound && !pv.Lowerbound) {
+			s.Pv = pv
+		}
+	}
+	return s, nil
+}
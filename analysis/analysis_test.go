@@ -0,0 +1,196 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/pgn"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeEngine is an engine.Engine that reports scripted scores for positions,
+// keyed by FEN.
+type fakeEngine struct {
+	scores map[string]engine.Pv
+	board  *chess.Board
+}
+
+type fakeInfo struct {
+	pv *engine.Pv
+}
+
+func (i fakeInfo) Err() error                    { return nil }
+func (i fakeInfo) BestMove() (chess.Move, bool)  { return chess.NullMove, i.pv == nil }
+func (i fakeInfo) Pv() *engine.Pv                { return i.pv }
+func (i fakeInfo) Stats() *engine.Stats          { return &engine.Stats{} }
+func (e *fakeEngine) SetPosition(b *chess.Board) { e.board = b }
+func (e *fakeEngine) Search() <-chan engine.Info { return e.search() }
+func (e *fakeEngine) Stop()                      {}
+func (e *fakeEngine) Quit() error                { return nil }
+func (e *fakeEngine) Ping() error                { return nil }
+func (e *fakeEngine) Options() map[string]engine.Option {
+	return nil
+}
+func (e *fakeEngine) SearchDepth(int) <-chan engine.Info {
+	return e.search()
+}
+func (e *fakeEngine) SearchTime(time.Duration) <-chan engine.Info {
+	return e.search()
+}
+func (e *fakeEngine) SearchNodes(int64) <-chan engine.Info {
+	return e.search()
+}
+func (e *fakeEngine) SearchClock(_, _, _, _ time.Duration, _ int) <-chan engine.Info {
+	return e.search()
+}
+
+func (e *fakeEngine) search() <-chan engine.Info {
+	infoc := make(chan engine.Info, 2)
+	if pv, ok := e.scores[e.board.Fen()]; ok {
+		infoc <- fakeInfo{&pv}
+	}
+	infoc <- fakeInfo{}
+	close(infoc)
+	return infoc
+}
+
+const scholarsMate = `[Result "1-0"] 1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0`
+
+// scores after each move of scholarsMate, the first for the start position
+var scholarsMateScores = []engine.Pv{
+	{Score: 30},
+	{Score: 30},             // e4
+	{Score: 40},             // e5
+	{Score: -30},            // Qh5, loses 70
+	{Score: -20},            // Nc6
+	{Score: -200},           // Bc4, loses 170
+	{Score: 1, Mate: true},  // Nf6, loses the game
+	{Score: 99, Mate: true}, // not searched: Qxf7 is mate
+}
+
+func scholarsMateGame(t *testing.T) (*pgn.Game, *fakeEngine) {
+	var db pgn.DB
+	if errs := db.Parse(scholarsMate); errs != nil {
+		t.Fatal(errs)
+	}
+	g := db.Games[0]
+	if err := g.ParseMoves(); err != nil {
+		t.Fatal(err)
+	}
+	e := &fakeEngine{scores: map[string]engine.Pv{}}
+	e.scores[g.Root.Board.Fen()] = scholarsMateScores[0]
+	for i, node := range g.MainLine() {
+		e.scores[node.Board.Fen()] = scholarsMateScores[i+1]
+	}
+	return g, e
+}
+
+func nags(g *pgn.Game) [][]pgn.Nag {
+	var nags [][]pgn.Nag
+	for _, node := range g.MainLine() {
+		nags = append(nags, node.Nags)
+	}
+	return nags
+}
+
+func TestAnnotate(t *testing.T) {
+	g, e := scholarsMateGame(t)
+	if err := Annotate(g, e, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]pgn.Nag{nil, nil, {6}, nil, {2}, {4}, nil}
+	if got := nags(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("got NAGs %v, want %v", got, want)
+	}
+	var evals []string
+	for _, node := range g.MainLine() {
+		cp, mate, ok := node.Eval()
+		switch {
+		case !ok:
+			evals = append(evals, "")
+		case mate != 0:
+			evals = append(evals, fmt.Sprintf("#%d", mate))
+		default:
+			evals = append(evals, fmt.Sprint(cp))
+		}
+	}
+	wantEvals := []string{"30", "40", "-30", "-20", "-200", "#1", ""}
+	if !reflect.DeepEqual(evals, wantEvals) {
+		t.Errorf("got evals %q, want %q", evals, wantEvals)
+	}
+
+	g, e = scholarsMateGame(t)
+	lenient := Thresholds{Inaccuracy: 100, Mistake: 200, Blunder: 1000}
+	if err := lenient.Annotate(g, e, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want = [][]pgn.Nag{nil, nil, nil, nil, {6}, {4}, nil}
+	if got := nags(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("lenient thresholds: got NAGs %v, want %v", got, want)
+	}
+}
+
+func TestAddPv(t *testing.T) {
+	var db pgn.DB
+	if errs := db.Parse(`[Result "*"] 1. e4 e5 2. Nf3 *`); errs != nil {
+		t.Fatal(errs)
+	}
+	g := db.Games[0]
+	if err := g.ParseMoves(); err != nil {
+		t.Fatal(err)
+	}
+	e4 := g.Root.Next
+	e5 := e4.Next
+	san := func(first *pgn.Node) []string {
+		var moves []string
+		for n := first; n != nil; n = n.Next {
+			moves = append(moves, n.Move.San(n.Parent.Board))
+		}
+		return moves
+	}
+	pv := func(b *chess.Board, moves ...string) *engine.Pv {
+		p := &engine.Pv{Score: 35}
+		for _, s := range moves {
+			m, err := b.ParseMove(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Moves = append(p.Moves, m)
+			b = b.MakeMove(m)
+		}
+		return p
+	}
+
+	// a variation to 1... e5
+	first := AddPv(e4, pv(e4.Board, "c5", "Nf3", "d6"))
+	if got, want := san(first), []string{"c5", "Nf3", "d6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("variation: got %v, want %v", got, want)
+	}
+	if vs := e5.Variations(); len(vs) != 1 || vs[0].Next != first {
+		t.Errorf("variation not attached to 1... e5")
+	}
+	if cp, _, ok := first.Eval(); !ok || cp != 35 {
+		t.Errorf("got eval %d, %v, want 35", cp, ok)
+	}
+	if got, want := san(e4.Next), []string{"e5", "Nf3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("main line changed to %v", got)
+	}
+
+	// a continuation of the main line, with a mate score
+	nf3 := e5.Next
+	p := pv(nf3.Board, "Nc6", "Bb5")
+	p.Score, p.Mate = 12, true
+	first = AddPv(nf3, p)
+	if got, want := san(e4), []string{"e4", "e5", "Nf3", "Nc6", "Bb5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("continuation: got %v, want %v", got, want)
+	}
+	if _, mate, ok := first.Eval(); !ok || mate != 12 {
+		t.Errorf("got mate %d, %v, want 12", mate, ok)
+	}
+
+	if AddPv(nf3, &engine.Pv{}) != nil {
+		t.Error("empty pv added")
+	}
+}
@@ -2,9 +2,11 @@
 package chess
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
@@ -44,6 +46,26 @@ type Piece uint8
 func (p Piece) Color() int { return int(p) & 0x01 }
 func (p Piece) Type() int  { return int(p) &^ 0x01 }
 
+// IsNone returns whether p is NoPiece (an empty square).
+func (p Piece) IsNone() bool { return p == NoPiece }
+
+// IsWhite returns whether p is a white piece. NoPiece is neither white nor
+// black.
+func (p Piece) IsWhite() bool { return p != NoPiece && p.Color() == White }
+
+// IsBlack returns whether p is a black piece.
+func (p Piece) IsBlack() bool { return p != NoPiece && p.Color() == Black }
+
+// IsSlider returns whether p is a bishop, rook or queen: a piece that moves
+// any number of squares in a direction until blocked.
+func (p Piece) IsSlider() bool {
+	switch p.Type() {
+	case Bishop, Rook, Queen:
+		return true
+	}
+	return false
+}
+
 var PieceLetters = []rune{
 	'.', ',',
 	'P', 'p',
@@ -73,6 +95,20 @@ func pieceFromChar(c rune) Piece {
 	return NoPiece
 }
 
+// ParsePiece returns the piece for a letter of PieceLetters: upper case for
+// White (PNBRQK) and lower case for Black (pnbrqk). It returns !ok for any
+// other character.
+func ParsePiece(c rune) (p Piece, ok bool) {
+	p = pieceFromChar(c)
+	return p, p != NoPiece
+}
+
+// Char returns the letter of the piece in PieceLetters, the inverse of
+// ParsePiece.
+func (p Piece) Char() rune {
+	return PieceLetters[p]
+}
+
 // Squares
 
 const (
@@ -150,6 +186,94 @@ func (sq Sq) String() string {
 	return squareNames[sq]
 }
 
+// North returns the square one rank up from the point of view of the given
+// player: towards rank 8 for White and towards rank 1 for Black. It returns
+// NoSquare if that is off the board, or if sq is NoSquare.
+func (sq Sq) North(color int) Sq {
+	return sq.move([]int{8, -8}[color])
+}
+
+// South returns the square one rank down from the point of view of the given
+// player, see North.
+func (sq Sq) South(color int) Sq {
+	return sq.move([]int{-8, 8}[color])
+}
+
+// East returns the square one file towards the h-file, or NoSquare if sq is
+// on the h-file.
+func (sq Sq) East() Sq { return sq.move(1) }
+
+// West returns the square one file towards the a-file, or NoSquare if sq is
+// on the a-file.
+func (sq Sq) West() Sq { return sq.move(-1) }
+
+// move is like step, but also returns NoSquare when starting from NoSquare.
+func (sq Sq) move(offset int) Sq {
+	if sq == NoSquare {
+		return NoSquare
+	}
+	return sq.step(offset)
+}
+
+// Distance returns the number of king moves between the squares (the
+// Chebyshev distance): the larger of the file and rank distances.
+func (a Sq) Distance(b Sq) int {
+	df, dr := abs(a.File()-b.File()), abs(a.Rank()-b.Rank())
+	if df > dr {
+		return df
+	}
+	return dr
+}
+
+// ManhattanDistance returns the sum of the file and rank distances between
+// the squares.
+func (a Sq) ManhattanDistance(b Sq) int {
+	return abs(a.File()-b.File()) + abs(a.Rank()-b.Rank())
+}
+
+// AllSquares returns the 64 squares in the order A1, B1, ..., H1, A2, ...,
+// H8, which is also the order of Board.Piece.
+func AllSquares() []Sq {
+	squares := make([]Sq, 64)
+	for i := range squares {
+		squares[i] = Sq(i)
+	}
+	return squares
+}
+
+// RankSquares returns the squares of the given rank (0-7), from the a-file to
+// the h-file. It returns nil for an invalid rank.
+func RankSquares(rank int) []Sq {
+	if rank < Rank1 || rank > Rank8 {
+		return nil
+	}
+	squares := make([]Sq, 8)
+	for file := range squares {
+		squares[file] = Square(file, rank)
+	}
+	return squares
+}
+
+// FileSquares returns the squares of the given file (0-7), from rank 1 to
+// rank 8. It returns nil for an invalid file.
+func FileSquares(file int) []Sq {
+	if file < FileA || file > FileH {
+		return nil
+	}
+	squares := make([]Sq, 8)
+	for rank := range squares {
+		squares[rank] = Square(file, rank)
+	}
+	return squares
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 func squareFromString(s string) Sq {
 	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
 		return NoSquare
@@ -184,6 +308,51 @@ type Board struct {
 func (b *Board) my(piece int) Piece  { return Piece(b.SideToMove | piece) }
 func (b *Board) opp(piece int) Piece { return Piece(b.SideToMove ^ 1 | piece) }
 
+// Each calls fn for every occupied square on the board, in order from A1 to H8.
+func (b *Board) Each(fn func(sq Sq, p Piece)) {
+	for i, p := range b.Piece {
+		if p != NoPiece {
+			fn(Sq(i), p)
+		}
+	}
+}
+
+// EachOf is like Each, but only calls fn for the pieces of the given color.
+func (b *Board) EachOf(color int, fn func(sq Sq, p Piece)) {
+	b.Each(func(sq Sq, p Piece) {
+		if p.Color() == color {
+			fn(sq, p)
+		}
+	})
+}
+
+// PieceMap returns the pieces on the board by square, leaving out empty
+// squares.
+func (b *Board) PieceMap() map[Sq]Piece {
+	m := make(map[Sq]Piece)
+	b.Each(func(sq Sq, p Piece) { m[sq] = p })
+	return m
+}
+
+// FromPieceMap returns a board with the pieces of m and the given side to
+// move, like PieceMap in reverse. Entries for NoPiece or squares off the board
+// are ignored. The position has no castling rights and no en-passant square,
+// and the move counters are 0 and 1.
+func FromPieceMap(m map[Sq]Piece, side int) *Board {
+	b := &Board{
+		SideToMove: side,
+		MoveNr:     1,
+		EpSquare:   NoSquare,
+		CastleSq:   [4]Sq{NoSquare, NoSquare, NoSquare, NoSquare},
+	}
+	for sq, p := range m {
+		if sq >= A1 && sq <= H8 {
+			b.Piece[sq] = p
+		}
+	}
+	return b
+}
+
 // MustParseFen is like ParseFen, but panics if fen cannot be parsed.
 func MustParseFen(fen string) *Board {
 	b, err := ParseFen(fen)
@@ -193,6 +362,77 @@ func MustParseFen(fen string) *Board {
 	return b
 }
 
+// ParseFenStrict is like ParseFen, but also returns an error if the castling
+// rights do not match the board: every castling letter, including those of
+// the KQkq default, must refer to a king on its first rank and a rook of the
+// same color on the named side or file. ParseFen silently drops such rights.
+func ParseFenStrict(fen string) (*Board, error) {
+	b, err := ParseFen(fen)
+	if err != nil {
+		return nil, err
+	}
+	castling := "KQkq"
+	if f := strings.Fields(fen); len(f) > 2 {
+		castling = f[2]
+	}
+	if castling == "-" {
+		return b, nil
+	}
+	for _, c := range castling {
+		if !b.hasCastleRight(c) {
+			return nil, fmt.Errorf("fen error: castling right %c does not match the position", c)
+		}
+	}
+	return b, nil
+}
+
+// hasCastleRight returns whether the castling letter c of a FEN, as
+// interpreted by setCanCastle, is matched by one of the castling rights.
+func (b *Board) hasCastleRight(c rune) bool {
+	color := White
+	if unicode.IsLower(c) {
+		color = Black
+	}
+	king := b.find(Piece(color|King), A1, H8)
+	if king == NoSquare {
+		return false
+	}
+	switch unicode.ToUpper(c) {
+	case 'K':
+		return b.CastleSq[color|kingSide] != NoSquare
+	case 'Q':
+		return b.CastleSq[color|queenSide] != NoSquare
+	}
+	file := int(unicode.ToUpper(c) - 'A')
+	if file < FileA || file > FileH {
+		return false
+	}
+	wing := kingSide
+	if file < king.File() {
+		wing = queenSide
+	}
+	rook := b.CastleSq[color|wing]
+	return rook != NoSquare && rook.File() == file
+}
+
+// ParseBoard returns the position with the given piece placement, the first
+// field of a FEN, and side to move (White or Black). Unlike ParseFen, which
+// defaults the omitted castling rights to KQkq, the position has no castling
+// rights. There is no en-passant square and the move counters are 0 and 1.
+func ParseBoard(placement string, side int) (*Board, error) {
+	if side != White && side != Black {
+		return nil, fmt.Errorf("fen error: invalid side to move %d", side)
+	}
+	if placement == "" || strings.ContainsAny(placement, " \t") {
+		return nil, fmt.Errorf("fen error: %q is not a piece placement", placement)
+	}
+	return ParseFen(fmt.Sprintf("%s %c - - 0 1", placement, "wb"[side]))
+}
+
+// maxFenLength bounds the length of the FENs accepted by ParseFen. Valid FENs
+// are less than 100 characters long.
+const maxFenLength = 256
+
 // ParseFen initializes a board with the given FEN string. Fields omitted from
 // fen will default to the value in the starting position of a regular chess
 // game (e.g. 'w' for the side-to-move), so that ParseFen("") returns the
@@ -200,10 +440,27 @@ func MustParseFen(fen string) *Board {
 //
 // For castling rights both the conventional KkQq can be used as well as file
 // letters, for example 'C' for a white rook on the c-file that can castle.
-// The latter is sometimes needed for chess960 positions.
+// The latter is sometimes needed for chess960 positions: K and Q always refer
+// to the outermost rook on their side of the king. A file letter takes
+// precedence over K or Q for the same wing, regardless of the order in which
+// they appear; otherwise the first right given for a wing is used. Duplicate
+// and conflicting letters are ignored, as are rights without a matching king
+// and rook.
+//
+// The move counters default to "0 1" if they are missing. Negative counters
+// are clamped to their smallest valid values.
+//
+// ParseFen returns an error for any malformed input, including FENs longer
+// than a few hundred characters; it does not panic.
 func ParseFen(fen string) (b *Board, err error) {
+	if len(fen) > maxFenLength {
+		return nil, fmt.Errorf("fen error: longer than %d characters", maxFenLength)
+	}
 	i, j := 0, 0
 	parseError := func(msg interface{}) (*Board, error) {
+		if i > len(fen) {
+			i = len(fen)
+		}
 		return nil, fmt.Errorf("%s·%s: fen error: %s", fen[0:i], fen[i:], msg)
 	}
 	isSpace := func(c byte) bool {
@@ -224,15 +481,21 @@ func ParseFen(fen string) (b *Board, err error) {
 
 	// field 1: pieces
 	fen, i, j = nextField(fen, i, j, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR")
-	for file, rank := 0, 7; i < j; i++ {
+	file, rank := 0, 7
+	for ; i < j; i++ {
 		switch c := rune(fen[i]); c {
 		case '/':
+			if file < 8 {
+				return parseError("too few files")
+			}
 			if rank--; rank < 0 {
 				return parseError("too many ranks")
 			}
 			file = 0
 		case '1', '2', '3', '4', '5', '6', '7', '8':
-			file += int(c - '0')
+			if file += int(c - '0'); file > 8 {
+				return parseError("too many files")
+			}
 		default:
 			if file > 7 {
 				return parseError("too many files")
@@ -245,6 +508,12 @@ func ParseFen(fen string) (b *Board, err error) {
 			file++
 		}
 	}
+	if file < 8 {
+		return parseError("too few files")
+	}
+	if rank > 0 {
+		return parseError("too few ranks")
+	}
 
 	// field 2: side-to-move
 	fen, i, j = nextField(fen, i, j, "w")
@@ -263,9 +532,15 @@ func ParseFen(fen string) (b *Board, err error) {
 	}
 	fen, i, j = nextField(fen, i, j, "KQkq")
 	if fen[i:j] != "-" {
-		for ; i < j; i++ {
-			c := int(fen[i])
-			b.setCanCastle(c, true)
+		// File letters name the rook exactly, so they are applied
+		// before K/Q, which only pick the outermost rook of a wing.
+		// The first right given for a wing wins.
+		for _, kq := range []bool{false, true} {
+			for k := i; k < j; k++ {
+				if c := fen[k]; strings.IndexByte("KQkq", c) >= 0 == kq {
+					b.setCanCastle(int(c), true)
+				}
+			}
 		}
 	}
 
@@ -280,23 +555,145 @@ func ParseFen(fen string) (b *Board, err error) {
 		}
 	}
 
-	// field 5: halfmove counter for the 50-move rule
+	// field 5: halfmove counter for the 50-move rule; missing or empty
+	// counters get their defaults and negative ones are clamped, as some
+	// generators are sloppy with them
 	fen, i, j = nextField(fen, i, j, "0")
 	if b.Rule50, err = strconv.Atoi(fen[i:j]); err != nil {
 		return parseError(err)
 	}
+	if b.Rule50 < 0 {
+		b.Rule50 = 0
+	}
 
 	// field 6: fullmove counter
 	fen, i, j = nextField(fen, i, j, "1")
 	if b.MoveNr, err = strconv.Atoi(fen[i:j]); err != nil {
 		return parseError(err)
 	}
+	if b.MoveNr < 1 {
+		b.MoveNr = 1
+	}
 
 	return b, nil
 }
 
-// Fen returns the FEN string (Forsyth-Edwards Notation) of the position.
+// Equal returns whether b and other are the same position: they have the same
+// pieces on the same squares, the same side to move, castling rights and
+// en-passant square. Like in Hash, the en-passant square only counts if a pawn
+// of the side to move stands next to the pawn that can be captured, so that
+// equal positions have equal hashes. The move counters are not compared.
+func (b *Board) Equal(other *Board) bool {
+	if b.Piece != other.Piece || b.SideToMove != other.SideToMove ||
+		b.CastleSq != other.CastleSq {
+		return false
+	}
+	if b.hasEpCapturer() || other.hasEpCapturer() {
+		return b.EpSquare == other.EpSquare
+	}
+	return true
+}
+
+// Validate checks that the position is legal: each side has exactly one
+// king, there are no pawns on the first or last rank, the side that is not to
+// move is not in check, castling rights refer to a king and rook on their
+// first rank, and an en-passant square lies behind a pawn that can just have
+// moved two squares.
+func (b *Board) Validate() error {
+	for _, color := range []int{White, Black} {
+		if n := b.Count(Piece(color | King)); n != 1 {
+			return fmt.Errorf("%d %s kings", n, []string{"white", "black"}[color])
+		}
+	}
+	for sq, p := range b.Piece {
+		if r := Sq(sq).Rank(); p.Type() == Pawn && (r == Rank1 || r == Rank8) {
+			return fmt.Errorf("pawn on %v", Sq(sq))
+		}
+	}
+	if _, check := b.pseudoLegalMoves(); check {
+		return errors.New("the side not to move is in check")
+	}
+	for i, rookSq := range b.CastleSq {
+		if rookSq == NoSquare {
+			continue
+		}
+		color, wing := i&0x01, i&^0x01
+		king := b.find(Piece(color|King), A1, H8)
+		if b.Piece[rookSq] != Piece(color|Rook) || rookSq.RelativeRank(color) != Rank1 ||
+			king.RelativeRank(color) != Rank1 ||
+			(wing == kingSide) != (rookSq > king) {
+			return fmt.Errorf("invalid castling right for the rook on %v", rookSq)
+		}
+	}
+	if ep := b.EpSquare; ep != NoSquare {
+		pawn := Square(ep.File(), []int{Rank5, Rank4}[b.SideToMove])
+		from := Square(ep.File(), []int{Rank7, Rank2}[b.SideToMove])
+		if ep.RelativeRank(b.SideToMove) != Rank6 || b.Piece[ep] != NoPiece ||
+			b.Piece[from] != NoPiece || b.Piece[pawn] != b.opp(Pawn) {
+			return fmt.Errorf("invalid en-passant square %v", ep)
+		}
+	}
+	return nil
+}
+
+// Fen returns the FEN string (Forsyth-Edwards Notation) of the position. As
+// is common practice, the en-passant square is only given if a pawn of the
+// side to move can legally capture en passant; use RawFen to get EpSquare as
+// it is. Hash and Equal follow Polyglot instead and count the en-passant
+// square whenever a pawn stands next to the one that can be captured, even if
+// it is pinned, so positions with the same FEN may still differ for them.
 func (b *Board) Fen() string {
+	return fmt.Sprintf("%s %d %d", b.PositionFen(), b.Rule50, b.MoveNr)
+}
+
+// RawFen is like Fen, but writes EpSquare even if no en-passant capture is
+// possible, as after every double pawn push.
+func (b *Board) RawFen() string {
+	return fmt.Sprintf("%s %d %d", b.positionFen(false, true), b.Rule50, b.MoveNr)
+}
+
+// canCaptureEnPassant returns whether a pawn of the side to move can legally
+// capture en passant on EpSquare.
+func (b *Board) canCaptureEnPassant() bool {
+	ep := b.EpSquare
+	if ep == NoSquare {
+		return false
+	}
+	rank := []int{Rank5, Rank4}[b.SideToMove]
+	if b.Piece[Square(ep.File(), rank)] != b.opp(Pawn) {
+		return false
+	}
+	for _, file := range []int{ep.File() - 1, ep.File() + 1} {
+		if file < FileA || file > FileH {
+			continue
+		}
+		from := Square(file, rank)
+		if b.Piece[from] == b.my(Pawn) && (Move{from, ep, NoPiece}).isLegal(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// PositionFen returns the first four fields of the FEN string of the position:
+// the piece placement, side to move, castling rights and en-passant square.
+// Unlike Fen, it does not include the move counters, which makes it suitable
+// as a key to identify positions.
+func (b *Board) PositionFen() string {
+	return b.positionFen(false, false)
+}
+
+// ShredderFen is like Fen, but writes the castling rights in Shredder-FEN
+// style: always as the files of the castling rooks, for example "HAha" for
+// the starting position. Some engines require this for chess960.
+func (b *Board) ShredderFen() string {
+	return fmt.Sprintf("%s %d %d", b.positionFen(true, false), b.Rule50, b.MoveNr)
+}
+
+// positionFen returns the first four FEN fields, with castling rights in
+// Shredder-FEN style if shredder is set. The en-passant square is written only
+// if an en-passant capture is possible, unless raw is set.
+func (b *Board) positionFen(shredder, raw bool) string {
 	var fen strings.Builder
 
 	// field 1: pieces
@@ -332,31 +729,31 @@ func (b *Board) Fen() string {
 	// file letters for other rooks so that for regular chess we always use
 	// K/Q. Note that this means that for chess960 K/Q always indicates the
 	// rook on the h/a file, not another rook that happens to be on the
-	// same side of the king.
+	// same side of the king. Shredder-FEN always uses file letters.
 	len := fen.Len()
 	if sq := b.CastleSq[WhiteOO]; sq != NoSquare {
-		if sq == H1 {
+		if sq == H1 && !shredder {
 			fen.WriteRune('K')
 		} else {
 			fen.WriteRune(rune('A' + sq.File()))
 		}
 	}
 	if sq := b.CastleSq[WhiteOOO]; sq != NoSquare {
-		if sq == A1 {
+		if sq == A1 && !shredder {
 			fen.WriteRune('Q')
 		} else {
 			fen.WriteRune(rune('A' + sq.File()))
 		}
 	}
 	if sq := b.CastleSq[BlackOO]; sq != NoSquare {
-		if sq == H8 {
+		if sq == H8 && !shredder {
 			fen.WriteRune('k')
 		} else {
 			fen.WriteRune(rune('a' + sq.File()))
 		}
 	}
 	if sq := b.CastleSq[BlackOOO]; sq != NoSquare {
-		if sq == A8 {
+		if sq == A8 && !shredder {
 			fen.WriteRune('q')
 		} else {
 			fen.WriteRune(rune('a' + sq.File()))
@@ -367,13 +764,18 @@ func (b *Board) Fen() string {
 	}
 	fen.WriteByte(' ')
 
-	// fields 4-6
-	fmt.Fprintf(&fen, "%s %d %d", b.EpSquare, b.Rule50, b.MoveNr)
+	// field 4: en-passant square
+	if raw || b.canCaptureEnPassant() {
+		fen.WriteString(b.EpSquare.String())
+	} else {
+		fen.WriteByte('-')
+	}
 	return fen.String()
 }
 
 // setCanCastle sets or unsets castling rights. c is the file of the rook with
-// which to castle ('A'...'H') or 'K'/'Q' for kingside/queenside castling.
+// which to castle ('A'...'H') or 'K'/'Q' for kingside/queenside castling. A
+// right that is already set for the wing is not replaced.
 // Uppercase for White, lowercase for Black.
 func (b *Board) setCanCastle(c int, can bool) {
 	var (
@@ -422,12 +824,72 @@ func (b *Board) setCanCastle(c int, can bool) {
 		wing = queenSide
 	}
 	if can {
-		b.CastleSq[color|wing] = rookSq
+		if b.CastleSq[color|wing] == NoSquare {
+			b.CastleSq[color|wing] = rookSq
+		}
 	} else {
 		b.CastleSq[color|wing] = NoSquare
 	}
 }
 
+// SetPiece puts piece p (or NoPiece to clear the square) on sq. Castling
+// rights that depend on a king or rook that is removed from sq are dropped,
+// as is the en-passant square if the capturable pawn is affected.
+func (b *Board) SetPiece(sq Sq, p Piece) {
+	old := b.Piece[sq]
+	if old == p {
+		return
+	}
+	b.Piece[sq] = p
+	switch old.Type() {
+	case King:
+		b.CastleSq[old.Color()|kingSide] = NoSquare
+		b.CastleSq[old.Color()|queenSide] = NoSquare
+	case Rook:
+		for i, rookSq := range b.CastleSq {
+			if rookSq == sq {
+				b.CastleSq[i] = NoSquare
+			}
+		}
+	}
+	if ep := b.EpSquare; ep != NoSquare {
+		// the pawn that just moved two squares is on the rank
+		// between the ep square and the side to move
+		pawnSq := Square(ep.File(), []int{Rank5, Rank4}[b.SideToMove])
+		if sq == ep || sq == pawnSq {
+			b.EpSquare = NoSquare
+		}
+	}
+}
+
+// SetSideToMove sets the side to move to color. The en-passant square is
+// cleared if the side to move changes.
+func (b *Board) SetSideToMove(color int) {
+	if b.SideToMove != color {
+		b.SideToMove = color
+		b.EpSquare = NoSquare
+	}
+}
+
+// ClearEnPassant removes the en-passant square.
+func (b *Board) ClearEnPassant() {
+	b.EpSquare = NoSquare
+}
+
+// MakeMoveChecked is like MakeMove, but first verifies that m is one of the
+// LegalMoves of the position. If it is not, an error wrapping ErrNoSuchMove is
+// returned and the board is left alone. Use it for moves from untrusted
+// sources; MakeMove itself assumes a legal move and may corrupt the board
+// otherwise.
+func (b *Board) MakeMoveChecked(m Move) (*Board, error) {
+	for _, legal := range b.LegalMoves() {
+		if m == legal {
+			return b.MakeMove(m), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v-%v", ErrNoSuchMove, m.From, m.To)
+}
+
 // MakeMove returns a copy of the Board with move m applied.
 func (b Board) MakeMove(m Move) *Board {
 	epSquare := b.EpSquare // remember en passant square
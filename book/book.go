@@ -0,0 +1,101 @@
+// Package book reads Polyglot opening books
+// (http://hgm.nubati.net/book_format.html).
+package book
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/malbrecht/chess"
+	"io/ioutil"
+	"sort"
+)
+
+// Book is a Polyglot opening book.
+type Book struct {
+	entries []entry // sorted by key
+}
+
+// entry is an entry in a Polyglot book file.
+type entry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+}
+
+// Entry is a book move for a position.
+type Entry struct {
+	Move   chess.Move
+	Weight int // relative weight of the move; higher is better
+}
+
+// entrySize is the size of an entry in a Polyglot book file: the key, the
+// move, the weight and 4 bytes of learning data.
+const entrySize = 16
+
+// Open reads the Polyglot book file at path.
+func Open(path string) (*Book, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%entrySize != 0 {
+		return nil, fmt.Errorf("%s: not a polyglot book", path)
+	}
+	bk := &Book{entries: make([]entry, len(data)/entrySize)}
+	for i := range bk.entries {
+		e := data[i*entrySize:]
+		bk.entries[i] = entry{
+			key:    binary.BigEndian.Uint64(e[0:8]),
+			move:   binary.BigEndian.Uint16(e[8:10]),
+			weight: binary.BigEndian.Uint16(e[10:12]),
+		}
+	}
+	if !sort.SliceIsSorted(bk.entries, func(i, j int) bool {
+		return bk.entries[i].key < bk.entries[j].key
+	}) {
+		return nil, fmt.Errorf("%s: book entries are not sorted", path)
+	}
+	return bk, nil
+}
+
+// Lookup returns the book moves for position b, in the order of the book
+// (usually by decreasing weight). Moves that are not legal in the position
+// are skipped.
+func (bk *Book) Lookup(b *chess.Board) []Entry {
+	key := b.Hash()
+	i := sort.Search(len(bk.entries), func(i int) bool {
+		return bk.entries[i].key >= key
+	})
+	var entries []Entry
+	for ; i < len(bk.entries) && bk.entries[i].key == key; i++ {
+		if m, ok := decodeMove(b, bk.entries[i].move); ok {
+			entries = append(entries, Entry{m, int(bk.entries[i].weight)})
+		}
+	}
+	return entries
+}
+
+// decodeMove decodes a Polyglot move in position b. The move is encoded as
+// to-file (bits 0-2), to-rank (3-5), from-file (6-8), from-rank (9-11) and
+// promotion piece (12-14: none, knight, bishop, rook, queen). Castling moves
+// are encoded as the king capturing its own rook, like chess.Move.
+func decodeMove(b *chess.Board, move uint16) (chess.Move, bool) {
+	field := func(shift uint) int { return int(move>>shift) & 7 }
+	m := chess.Move{
+		From: chess.Square(field(6), field(9)),
+		To:   chess.Square(field(0), field(3)),
+	}
+	if promotion := field(12); promotion != 0 {
+		if promotion > 4 {
+			return chess.NullMove, false
+		}
+		pieces := []int{chess.Knight, chess.Bishop, chess.Rook, chess.Queen}
+		m.Promotion = chess.Piece(b.SideToMove | pieces[promotion-1])
+	}
+	for _, legal := range b.LegalMovesFrom(m.From) {
+		if legal == m {
+			return m, true
+		}
+	}
+	return chess.NullMove, false
+}
@@ -0,0 +1,46 @@
+package book
+
+import (
+	"github.com/malbrecht/chess"
+	"reflect"
+	"testing"
+)
+
+type lookupTest struct {
+	fen     string
+	moves   []string // in SAN
+	weights []int
+}
+
+var lookupTests = []lookupTest{
+	{"", []string{"e4", "d4", "Nf3"}, []int{100, 80, 20}},
+	{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", []string{"c5", "e5"}, []int{50, 40}},
+	// castling is encoded as king-takes-rook; the illegal Ke6 is skipped
+	{"r3k2r/pppq1ppp/2n2n2/3pp3/3PP3/2N2N2/PPPQ1PPP/R3K2R w KQkq - 0 1", []string{"O-O", "O-O-O"}, []int{30, 10}},
+	{"rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 1", nil, nil},
+}
+
+func TestLookup(t *testing.T) {
+	bk, err := Open("testdata/small.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range lookupTests {
+		b := chess.MustParseFen(test.fen)
+		var moves []string
+		var weights []int
+		for _, e := range bk.Lookup(b) {
+			moves = append(moves, e.Move.San(b))
+			weights = append(weights, e.Weight)
+		}
+		if !reflect.DeepEqual(moves, test.moves) || !reflect.DeepEqual(weights, test.weights) {
+			t.Errorf("%s: got %v %v, want %v %v", b.Fen(), moves, weights, test.moves, test.weights)
+		}
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	if _, err := Open("testdata/missing.bin"); err == nil {
+		t.Error("no error opening a missing book")
+	}
+}
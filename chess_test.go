@@ -1,8 +1,11 @@
 package chess
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -80,7 +83,8 @@ var fenTests = []fenTest{
 		EpSquare:   C3,
 		CastleSq:   [4]Sq{A1, NoSquare, H1, NoSquare}},
 
-		"r4rk1/2pp1ppp/8/8/5P2/8/PPPPP1PP/RNBQKBNR b KQ c3 0 12",
+		// no black pawn can capture on c3
+		"r4rk1/2pp1ppp/8/8/5P2/8/PPPPP1PP/RNBQKBNR b KQ - 0 12",
 	},
 }
 
@@ -102,11 +106,399 @@ func TestFEN(t *testing.T) {
 	}
 }
 
+func TestFenEpSquare(t *testing.T) {
+	tests := []struct{ moves, fen, raw string }{
+		// no black pawn next to e4
+		{"e2e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+			"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+		// exd6 is possible
+		{"e2e4 a7a6 e4e5 d7d5", "rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+			"rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3"},
+	}
+	for _, test := range tests {
+		b, err := MustParseFen("").DoMoves(strings.Fields(test.moves))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fen := b.Fen(); fen != test.fen {
+			t.Errorf("%s: got %q, want %q", test.moves, fen, test.fen)
+		}
+		if raw := b.RawFen(); raw != test.raw {
+			t.Errorf("%s: got raw FEN %q, want %q", test.moves, raw, test.raw)
+		}
+	}
+	// dxc3 would expose the black king to the rook on a4
+	pinned := MustParseFen("8/8/8/8/R2pk3/8/2P5/4K3 w - - 0 1")
+	pinned = pinned.MakeMove(Move{C2, C4, NoPiece})
+	if fen, want := pinned.Fen(), "8/8/8/8/R1Ppk3/8/8/4K3 b - - 0 1"; fen != want {
+		t.Errorf("pinned pawn: got %q, want %q", fen, want)
+	}
+	// Hash still counts the en-passant square
+	noEp := *pinned
+	noEp.EpSquare = NoSquare
+	if noEp.Fen() != pinned.Fen() || noEp.Hash() == pinned.Hash() {
+		t.Errorf("pinned pawn: want equal FENs, but different hashes")
+	}
+}
+
+func TestPositionFen(t *testing.T) {
+	b1, _ := MustParseFen("").DoMoves(strings.Fields("g1f3 g8f6 b1c3"))
+	b2, _ := MustParseFen("").DoMoves(strings.Fields("b1c3 g8f6 g1f3"))
+	b3, _ := MustParseFen("").DoMoves(strings.Fields("g1f3 g8f6 f3g1 f6g8"))
+	want := "rnbqkb1r/pppppppp/5n2/8/8/2N2N2/PPPPPPPP/R1BQKB1R b KQkq -"
+	if fen1, fen2 := b1.PositionFen(), b2.PositionFen(); fen1 != want || fen2 != want {
+		t.Errorf("transposition: got %q and %q, want %q", fen1, fen2, want)
+	}
+	start := MustParseFen("")
+	if fen, want := b3.PositionFen(), start.PositionFen(); fen != want || b3.Fen() == start.Fen() {
+		t.Errorf("start position after 4 knight moves: got %q, want %q", fen, want)
+	}
+}
+
+func TestShredderFen(t *testing.T) {
+	tests := []struct{ fen, shredder string }{
+		{"", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		// chess960 with rooks on the b- and g-files
+		{"nrbkqbrn/pppppppp/8/8/8/8/PPPPPPPP/NRBKQBRN w GBgb - 0 1",
+			"nrbkqbrn/pppppppp/8/8/8/8/PPPPPPPP/NRBKQBRN w GBgb - 0 1"},
+		{"4k3/8/8/8/8/8/8/4K2R w K - 0 1", "4k3/8/8/8/8/8/8/4K2R w H - 0 1"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "4k3/8/8/8/8/8/8/4K3 w - - 0 1"},
+	}
+	for _, test := range tests {
+		if fen := MustParseFen(test.fen).ShredderFen(); fen != test.shredder {
+			t.Errorf("%q: got %q, want %q", test.fen, fen, test.shredder)
+		}
+	}
+}
+
+func TestFENCastling960(t *testing.T) {
+	// king on b1 between the rooks on a1 and c1, a third rook on h1
+	const pos = "rkrbbnnr/pppppppp/8/8/8/8/PPPPPPPP/RKRBBNNR w "
+	tests := []struct {
+		castling string
+		want     [4]Sq // WhiteOOO, BlackOOO, WhiteOO, BlackOO
+	}{
+		{"CAca", [4]Sq{A1, A8, C1, C8}},
+		{"KQkq", [4]Sq{A1, A8, H1, H8}},
+		{"HAha", [4]Sq{A1, A8, H1, H8}},
+		// file letters win over K/Q, wherever they appear
+		{"KQCkqc", [4]Sq{A1, A8, C1, C8}},
+		{"CKQckq", [4]Sq{A1, A8, C1, C8}},
+		// duplicates are ignored, the first of conflicting letters wins
+		{"CCAAcca", [4]Sq{A1, A8, C1, C8}},
+		{"CHch", [4]Sq{NoSquare, NoSquare, C1, C8}},
+		{"-", [4]Sq{NoSquare, NoSquare, NoSquare, NoSquare}},
+	}
+	for _, test := range tests {
+		b, err := ParseFen(pos + test.castling + " - 0 1")
+		if err != nil {
+			t.Errorf("%s: %v", test.castling, err)
+			continue
+		}
+		if b.CastleSq != test.want {
+			t.Errorf("%s: got castling rooks %v, want %v", test.castling, b.CastleSq, test.want)
+		}
+	}
+}
+
+func TestFENCounters(t *testing.T) {
+	const pos = "4k3/8/8/8/8/8/8/4K3 w - -"
+	tests := []struct {
+		fen            string
+		rule50, moveNr int
+	}{
+		{pos, 0, 1},                   // both counters missing
+		{pos + " ", 0, 1},             // empty trailing field
+		{pos + " \t ", 0, 1},          // whitespace only
+		{pos + " 100", 100, 1},        // fullmove counter missing
+		{pos + " 100 250 ", 100, 250}, // large values
+		{pos + " -3 -1", 0, 1},        // negative values are clamped
+		{pos + " 7 0", 7, 1},
+	}
+	for _, test := range tests {
+		b, err := ParseFen(test.fen)
+		if err != nil {
+			t.Errorf("%q: %v", test.fen, err)
+			continue
+		}
+		if b.Rule50 != test.rule50 || b.MoveNr != test.moveNr {
+			t.Errorf("%q: got counters %d %d, want %d %d", test.fen, b.Rule50, b.MoveNr, test.rule50, test.moveNr)
+		}
+	}
+	if _, err := ParseFen(pos + " x 1"); err == nil {
+		t.Error("non-numeric counter accepted")
+	}
+}
+
+func TestParseBoard(t *testing.T) {
+	const placement = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR"
+	b, err := ParseBoard(placement, Black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := placement + " b - - 0 1"; b.Fen() != want {
+		t.Errorf("got %q, want %q", b.Fen(), want)
+	}
+	for i, sq := range b.CastleSq {
+		if sq != NoSquare {
+			t.Errorf("castling right %d set for %v", i, sq)
+		}
+	}
+	if b.EpSquare != NoSquare {
+		t.Errorf("got en-passant square %v", b.EpSquare)
+	}
+	for _, test := range []struct {
+		placement string
+		side      int
+	}{
+		{placement, 2},
+		{placement + " w", White},
+		{"", White},
+		{"8/8/8", White},
+	} {
+		if _, err := ParseBoard(test.placement, test.side); err == nil {
+			t.Errorf("ParseBoard(%q, %d): no error", test.placement, test.side)
+		}
+	}
+}
+
+func TestParseFenStrict(t *testing.T) {
+	tests := []struct {
+		fen string
+		ok  bool
+	}{
+		{"", true},
+		{"r3k2r/8/8/8/8/8/8/R3K3 w KQkq - 0 1", false}, // no rook on h1
+		{"r3k2r/8/8/8/8/8/8/R3K3 w Qkq - 0 1", true},
+		{"r3k2r/8/8/8/8/8/4K3/R6R w KQkq - 0 1", false}, // king not on the first rank
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", true},
+		{"rkrbbnnr/pppppppp/8/8/8/8/PPPPPPPP/RKRBBNNR w CAca - 0 1", true},
+		{"rkrbbnnr/pppppppp/8/8/8/8/PPPPPPPP/RKRBBNNR w DAda - 0 1", false}, // no rook on d1
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/4K3 w", false}, // the KQkq default
+	}
+	for _, test := range tests {
+		b, err := ParseFenStrict(test.fen)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: got error %v", test.fen, err)
+		}
+		if err == nil && b.Fen() != MustParseFen(test.fen).Fen() {
+			t.Errorf("%q: got %q", test.fen, b.Fen())
+		}
+	}
+	// ParseFen drops the right
+	b, err := ParseFen("r3k2r/8/8/8/8/8/8/R3K3 w KQkq - 0 1")
+	if err != nil || b.CastleSq[WhiteOO] != NoSquare || b.CastleSq[WhiteOOO] != A1 {
+		t.Errorf("ParseFen: got castling rooks %v, error %v", b.CastleSq, err)
+	}
+}
+
+var fenErrorTests = []struct {
+	fen, err string
+}{
+	{"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "too few files"},
+	{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN", "too few files"},
+	{"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "too many files"},
+	{"8p/8/8/8/8/8/8/8", "too many files"},
+	{"7/8/8/8/8/8/8/8", "too few files"},
+	{"8/8/8/8/8/8/8/p8", "too many files"},
+	{"8/8/8/8/8/8/8/5P4", "too many files"},
+	{"8/8/8/8/8/8/8", "too few ranks"},
+	{"8/8/8/8/8/8/8/8/8", "too many ranks"},
+	{"8/8/8/8/8/8/8/8 w - - 0 1" + strings.Repeat(" ", 300), "longer than 256 characters"},
+}
+
+func TestFENErrors(t *testing.T) {
+	for _, test := range fenErrorTests {
+		_, err := ParseFen(test.fen)
+		if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.fen, err, test.err)
+		}
+	}
+	if _, err := ParseFen("8/8/8/8/8/8/8/8"); err != nil {
+		t.Errorf("empty board: %s", err)
+	}
+}
+
+func TestParsePiece(t *testing.T) {
+	for _, p := range []Piece{WP, WN, WB, WR, WQ, WK, BP, BN, BB, BR, BQ, BK} {
+		got, ok := ParsePiece(p.Char())
+		if !ok || got != p {
+			t.Errorf("ParsePiece(%q) = %v, %v, want %v", p.Char(), got, ok, p)
+		}
+	}
+	for _, c := range ".,xZ1 " {
+		if p, ok := ParsePiece(c); ok {
+			t.Errorf("ParsePiece(%q) = %v, want !ok", c, p)
+		}
+	}
+}
+
+func TestSquareNeighbours(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want Sq
+	}{
+		{"E4.North(White)", E4.North(White), E5},
+		{"E4.North(Black)", E4.North(Black), E3},
+		{"E4.South(White)", E4.South(White), E3},
+		{"E4.South(Black)", E4.South(Black), E5},
+		{"E4.East()", E4.East(), F4},
+		{"E4.West()", E4.West(), D4},
+		{"H4.East()", H4.East(), NoSquare},
+		{"A4.West()", A4.West(), NoSquare},
+		{"H1.East()", H1.East(), NoSquare}, // not A2
+		{"A2.West()", A2.West(), NoSquare}, // not H1
+		{"E8.North(White)", E8.North(White), NoSquare},
+		{"E1.North(Black)", E1.North(Black), NoSquare},
+		{"NoSquare.North(White)", NoSquare.North(White), NoSquare},
+		{"NoSquare.East()", NoSquare.East(), NoSquare},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestSquareDistance(t *testing.T) {
+	tests := []struct {
+		a, b                Sq
+		distance, manhattan int
+	}{
+		{E4, E4, 0, 0},
+		{A1, H8, 7, 14},
+		{A1, B3, 2, 3},
+		{G7, C6, 4, 5},
+	}
+	for _, test := range tests {
+		if d := test.a.Distance(test.b); d != test.distance {
+			t.Errorf("%v.Distance(%v) = %d, want %d", test.a, test.b, d, test.distance)
+		}
+		if d := test.b.ManhattanDistance(test.a); d != test.manhattan {
+			t.Errorf("%v.ManhattanDistance(%v) = %d, want %d", test.b, test.a, d, test.manhattan)
+		}
+	}
+}
+
+func TestSquareLists(t *testing.T) {
+	all := AllSquares()
+	if len(all) != 64 || all[0] != A1 || all[63] != H8 {
+		t.Fatalf("AllSquares: got %v", all)
+	}
+	seen := make(map[Sq]bool)
+	for i, sq := range all {
+		if seen[sq] || (i > 0 && sq != all[i-1]+1) {
+			t.Errorf("AllSquares: %v out of order or repeated", sq)
+		}
+		seen[sq] = true
+	}
+	if got, want := RankSquares(Rank3), []Sq{A3, B3, C3, D3, E3, F3, G3, H3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RankSquares(Rank3) = %v, want %v", got, want)
+	}
+	if got, want := FileSquares(FileF), []Sq{F1, F2, F3, F4, F5, F6, F7, F8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FileSquares(FileF) = %v, want %v", got, want)
+	}
+	if RankSquares(8) != nil || FileSquares(-1) != nil {
+		t.Error("got squares for an invalid rank or file")
+	}
+}
+
+func TestPieceHelpers(t *testing.T) {
+	tests := []struct {
+		p                          Piece
+		none, white, black, slider bool
+	}{
+		{NoPiece, true, false, false, false},
+		{WP, false, true, false, false},
+		{BP, false, false, true, false},
+		{WQ, false, true, false, true},
+		{BQ, false, false, true, true},
+		{BN, false, false, true, false},
+		{WR, false, true, false, true},
+	}
+	for _, test := range tests {
+		if test.p.IsNone() != test.none || test.p.IsWhite() != test.white ||
+			test.p.IsBlack() != test.black || test.p.IsSlider() != test.slider {
+			t.Errorf("piece %d: got none %v white %v black %v slider %v", test.p,
+				test.p.IsNone(), test.p.IsWhite(), test.p.IsBlack(), test.p.IsSlider())
+		}
+	}
+}
+
+func TestRandomPosition(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	castling := 0
+	for i := 0; i < 1000; i++ {
+		b := RandomPosition(rng)
+		if err := b.Validate(); err != nil {
+			t.Fatalf("%s: %s", b.Fen(), err)
+		}
+		b2, err := ParseFen(b.Fen())
+		if err != nil {
+			t.Fatalf("%s: %s", b.Fen(), err)
+		}
+		if !reflect.DeepEqual(b, b2) {
+			t.Fatalf("%s: board changed by FEN round trip to %s", b.Fen(), b2.Fen())
+		}
+		if b.CastleSq != [4]Sq{NoSquare, NoSquare, NoSquare, NoSquare} {
+			castling++
+		}
+	}
+	if castling == 0 {
+		t.Error("no positions with castling rights")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct{ fen, err string }{
+		{"", ""},
+		{"4k3/8/8/8/8/8/8/8 w - - 0 1", "0 white kings"},
+		{"4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "2 white kings"},
+		{"4k3/8/8/8/8/8/8/4K2P w - - 0 1", "pawn on h1"},
+		{"4k3/8/8/8/8/8/8/4K2R b - - 0 1", ""},
+		{"4k2R/8/8/8/8/8/8/4K3 w - - 0 1", "the side not to move is in check"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1", "invalid en-passant square e6"},
+		{"rnbqkbnr/pppp1ppp/8/4p3/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1", ""},
+	}
+	for _, test := range tests {
+		err := MustParseFen(test.fen).Validate()
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%q: got error %v, want %q", test.fen, err, test.err)
+		}
+	}
+	b := MustParseFen("")
+	b.Piece[H1] = NoPiece // the castling right remains
+	if err := b.Validate(); err == nil || err.Error() != "invalid castling right for the rook on h1" {
+		t.Errorf("castling without rook: got error %v", err)
+	}
+}
+
+func FuzzParseFen(f *testing.F) {
+	for _, test := range fenTests {
+		f.Add(test.fen)
+	}
+	for _, test := range fenErrorTests {
+		f.Add(test.fen)
+	}
+	f.Fuzz(func(t *testing.T, fen string) {
+		b, err := ParseFen(fen)
+		if err != nil {
+			return
+		}
+		// a parsed board is written as a FEN that parses again
+		if _, err := ParseFen(b.Fen()); err != nil {
+			t.Errorf("%q: Fen() = %q does not parse: %s", fen, b.Fen(), err)
+		}
+	})
+}
+
 // ParseMove
 
 type parseMoveTest struct {
 	input string
 	move  Move
+	err   error
 }
 
 var parseMoveBoard = &Board{
@@ -128,30 +520,37 @@ var parseMoveBoard = &Board{
 }
 
 var parseMoveTests = []parseMoveTest{
-	{"a7a6", Move{A7, A6, NoPiece}},   // pawn move uci
-	{"a6", Move{A7, A6, NoPiece}},     // pawn move san
-	{"a7a5", Move{A7, A5, NoPiece}},   // double pawn move uci
-	{"a5", Move{A7, A5, NoPiece}},     // double pawn move san
-	{"f4g3", Move{F4, G3, NoPiece}},   // en-passant uci
-	{"fxg3", Move{F4, G3, NoPiece}},   // en-passant san
-	{"fg", Move{F4, G3, NoPiece}},     // very short pawn capture
-	{"b2b1q", Move{B2, B1, BQ}},       // promotion uci
-	{"b2b1r", Move{B2, B1, BR}},       // promotion uci
-	{"b2b1b", Move{B2, B1, BB}},       // promotion uci
-	{"b2b1n", Move{B2, B1, BN}},       // promotion uci
-	{"b1=Q", Move{B2, B1, BQ}},        // promotion san
-	{"b1/Q", Move{B2, B1, BQ}},        // promotion san
-	{"b1(Q)+?", Move{B2, B1, BQ}},     // promotion san
-	{"Nd4", Move{C6, D4, NoPiece}},    // knight move
-	{"Nc6-d4", Move{C6, D4, NoPiece}}, // knight move long notation
-	{"0-0", Move{E8, H8, NoPiece}},    // castling san
-	{"O-O", Move{E8, H8, NoPiece}},    // castling pgn
-	{"O-O-O", Move{E8, A8, NoPiece}},  // castling queenside
-	{"e8g8", Move{E8, H8, NoPiece}},   // castling uci
-	{"e8h8", Move{E8, H8, NoPiece}},   // castling uci960
+	{"a7a6", Move{A7, A6, NoPiece}, nil},      // pawn move uci
+	{"a6", Move{A7, A6, NoPiece}, nil},        // pawn move san
+	{"a7a5", Move{A7, A5, NoPiece}, nil},      // double pawn move uci
+	{"a5", Move{A7, A5, NoPiece}, nil},        // double pawn move san
+	{"f4g3", Move{F4, G3, NoPiece}, nil},      // en-passant uci
+	{"fxg3", Move{F4, G3, NoPiece}, nil},      // en-passant san
+	{"fxg3 e.p.", Move{F4, G3, NoPiece}, nil}, // en-passant marker
+	{"fxg3e.p.+", Move{F4, G3, NoPiece}, nil}, // en-passant marker
+	{"fxg3ep", Move{F4, G3, NoPiece}, nil},    // en-passant marker
+	{"fg", Move{F4, G3, NoPiece}, nil},        // very short pawn capture
+	{"b2b1q", Move{B2, B1, BQ}, nil},          // promotion uci
+	{"b2b1r", Move{B2, B1, BR}, nil},          // promotion uci
+	{"b2b1b", Move{B2, B1, BB}, nil},          // promotion uci
+	{"b2b1n", Move{B2, B1, BN}, nil},          // promotion uci
+	{"b1=Q", Move{B2, B1, BQ}, nil},           // promotion san
+	{"b1/Q", Move{B2, B1, BQ}, nil},           // promotion san
+	{"b1(Q)+?", Move{B2, B1, BQ}, nil},        // promotion san
+	{"Nd4", Move{C6, D4, NoPiece}, nil},       // knight move
+	{"Nc6-d4", Move{C6, D4, NoPiece}, nil},    // knight move long notation
+	{"0-0", Move{E8, H8, NoPiece}, nil},       // castling san
+	{"O-O", Move{E8, H8, NoPiece}, nil},       // castling pgn
+	{"O-O-O", Move{E8, A8, NoPiece}, nil},     // castling queenside
+	{"e8g8", Move{E8, H8, NoPiece}, nil},      // castling uci
+	{"e8h8", Move{E8, H8, NoPiece}, nil},      // castling uci960
+	{"♞d4", Move{C6, D4, NoPiece}, nil},       // figurine
+	{"♘d4", Move{C6, D4, NoPiece}, nil},       // figurine of the other color
+	{"b1=♛", Move{B2, B1, BQ}, nil},           // figurine promotion
+	{"♟fxg3", Move{F4, G3, NoPiece}, nil},     // pawn figurine
 	// invalid moves
-	{"Nb4", Move{}},  // ambiguous move
-	{"exf5", Move{}}, // the pawn is pinned
+	{"Nb4", Move{}, ErrAmbiguousMove}, // ambiguous move
+	{"exf5", Move{}, ErrNoSuchMove},   // the pawn is pinned
 }
 
 func TestParseMove(t *testing.T) {
@@ -164,6 +563,109 @@ func TestParseMove(t *testing.T) {
 			t.Errorf("move %s:\n\texp: %v\n\tgot: %v\n",
 				test.input, test.move, m)
 		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("move %s: got error %v, want %v", test.input, err, test.err)
+		}
+	}
+}
+
+func TestParseMoveWrongSide(t *testing.T) {
+	b := MustParseFen("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	tests := []struct{ input, err string }{
+		{"e2e4", "invalid move: no piece of the side to move can make this move"},
+		{"Ng1f3", "invalid move: no knight of the side to move can make this move"},
+		{"Bf1-c4", "invalid move: no bishop of the side to move can make this move"},
+		{"Nf3", "invalid move"}, // black has knights, they just can't go to f3
+	}
+	for _, test := range tests {
+		_, err := b.ParseMove(test.input)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %q", test.input, err, test.err)
+		}
+		if !errors.Is(err, ErrNoSuchMove) {
+			t.Errorf("%s: error %v is not ErrNoSuchMove", test.input, err)
+		}
+	}
+}
+
+func TestDoMoves(t *testing.T) {
+	b, err := MustParseFen("").DoMoves(strings.Fields("e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 e1h1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen, want := b.Fen(), "r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQ1RK1 b kq - 1 4"; fen != want {
+		t.Errorf("got %s, want %s", fen, want)
+	}
+	_, err = MustParseFen("").DoMoves([]string{"e2e4", "e7e5", "e4e5"})
+	if err == nil || !strings.HasPrefix(err.Error(), "move 3 (e4e5)") {
+		t.Errorf("illegal third move: got error %v", err)
+	}
+}
+
+func TestParseMoveVerify(t *testing.T) {
+	mate := MustParseFen("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4")
+	check := MustParseFen("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/4P3/8/PPPP1PPP/RNB1KBNR w KQkq - 4 4")
+	tests := []struct {
+		board *Board
+		input string
+		err   error
+	}{
+		{mate, "Qxf7#", nil},
+		{mate, "Qxf7#!", nil},
+		{mate, "Qxf7", nil}, // no symbol, not checked
+		{mate, "Qxf7+", ErrCheckMismatch},
+		{check, "Qxf7+", nil},
+		{check, "Qxf7#", ErrCheckMismatch},
+		{check, "Nf3+", ErrCheckMismatch},
+		{check, "Qxf8#", ErrNoSuchMove},
+	}
+	for _, test := range tests {
+		m, err := test.board.ParseMoveVerify(test.input)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got error %v, want %v", test.input, err, test.err)
+		}
+		if test.err == nil && m != (Move{H5, F7, NoPiece}) {
+			t.Errorf("%s: got move %v-%v", test.input, m.From, m.To)
+		}
+	}
+}
+
+func TestMakeMoveChecked(t *testing.T) {
+	b := MustParseFen("")
+	for _, m := range []Move{
+		{E4, E5, NoPiece}, // from an empty square
+		{E2, E5, NoPiece}, // pawn moving too far
+		{E1, E2, NoPiece}, // onto an own piece
+		{E7, E5, NoPiece}, // opponent's piece
+		NullMove,
+	} {
+		if nb, err := b.MakeMoveChecked(m); nb != nil || !errors.Is(err, ErrNoSuchMove) {
+			t.Errorf("%v-%v: got board %v and error %v, want ErrNoSuchMove", m.From, m.To, nb, err)
+		}
+	}
+	nb, err := b.MakeMoveChecked(Move{E2, E4, NoPiece})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := MustParseFen("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"); !nb.Equal(want) {
+		t.Errorf("e2-e4: got %s", nb.Fen())
+	}
+}
+
+func TestApplySAN(t *testing.T) {
+	b, moves, err := MustParseFen("").ApplySAN(strings.Fields("e4 e5 Nf3 Nc6 Bb5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen, want := b.Fen(), "r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3"; fen != want {
+		t.Errorf("got %s, want %s", fen, want)
+	}
+	if len(moves) != 5 || moves[4] != (Move{F1, B5, NoPiece}) {
+		t.Errorf("got moves %v", moves)
+	}
+	_, _, err = MustParseFen("").ApplySAN([]string{"e4", "e5", "Nf6"})
+	if err == nil || !strings.HasPrefix(err.Error(), "move 3 (Nf6)") {
+		t.Errorf("illegal third move: got error %v", err)
 	}
 }
 
@@ -194,12 +696,12 @@ var movegenTests = []movegenTest{
 			EpSquare:   C6,
 			CastleSq:   [4]Sq{A1, NoSquare, H1, NoSquare}},
 		moves: []string{
-			"Rb1", "Rc1", "Rd1", "O-O-O", "Kd1", "Kf1", "Rf1", "Rg1", "Kd2",
-			"Qb1", "Rh3", "Rh4", "Rh5", "Rh6", "Rxh7+", "a3", "a4", "Qd1",
-			"Rh2", "Qc2", "Qd2", "Qa3", "Qb3", "Qc3", "Qe3", "Qf3", "Qg3",
-			"Qh3", "Qc4", "Qxd4", "Qe4", "Qb5", "Qf5", "Qa6", "Qg6", "dxc6",
-			"d6", "Qxh7#", "bxa8=Q+", "b8=Q+", "bxa8=R+", "b8=R+", "bxa8=B",
-			"b8=B", "bxa8=N", "b8=N",
+			"Rb1", "Rc1", "Rd1", "O-O-O", "Kd1", "Kf1", "Kd2", "Rf1", "Rg1",
+			"Rh2", "Rh3", "Rh4", "Rh5", "Rh6", "Rxh7+", "a3", "a4", "Qb1",
+			"Qd1", "Qc2", "Qd2", "Qa3", "Qb3", "Qc3", "Qe3", "Qf3", "Qg3",
+			"Qh3", "Qc4", "Qxd4", "Qe4", "Qb5", "Qf5", "Qa6", "Qg6", "Qxh7#",
+			"dxc6", "d6", "bxa8=Q+", "bxa8=R+", "bxa8=B", "bxa8=N", "b8=Q+",
+			"b8=R+", "b8=B", "b8=N",
 		},
 	},
 }
@@ -215,3 +717,353 @@ func TestMovegen(t *testing.T) {
 		}
 	}
 }
+
+func TestCastlingOutOfPawnCheck(t *testing.T) {
+	b := MustParseFen("4k3/8/8/8/8/8/3p4/4K2R w K - 0 1")
+	for _, m := range b.LegalMoves() {
+		if m == (Move{E1, H1, NoPiece}) {
+			t.Error("castling allowed while in check from a pawn")
+		}
+	}
+}
+
+func BenchmarkLegalMoves(b *testing.B) {
+	board := MustParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	for i := 0; i < b.N; i++ {
+		board.LegalMoves()
+	}
+}
+
+func TestLegalMovesFrom(t *testing.T) {
+	b := MustParseFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	var want []Move
+	for _, m := range b.LegalMoves() {
+		if m.From == E1 {
+			want = append(want, m)
+		}
+	}
+	got := b.LegalMovesFrom(E1)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LegalMovesFrom(E1): got %v, want %v", got, want)
+	}
+	if len(got) != 7 { // five king steps and two castling moves
+		t.Errorf("LegalMovesFrom(E1): got %d moves, want 7", len(got))
+	}
+	if got := b.LegalMovesFrom(E8); got != nil {
+		t.Errorf("LegalMovesFrom(E8): got %v for the side not to move", got)
+	}
+	for _, sq := range []Sq{NoSquare, 64} {
+		if got := b.LegalMovesFrom(sq); got != nil {
+			t.Errorf("LegalMovesFrom(%d): got %v for a square off the board", sq, got)
+		}
+	}
+}
+
+func TestCastlePath(t *testing.T) {
+	tests := []struct {
+		fen                                string
+		wing                               int
+		kingFrom, kingTo, rookFrom, rookTo Sq
+		ok                                 bool
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", WhiteOO, E1, G1, H1, F1, true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", BlackOOO, E8, C8, A8, D8, true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Qkq - 0 1", WhiteOO, NoSquare, NoSquare, NoSquare, NoSquare, false},
+		// chess960 with the king on b1 and the rooks on a1 and e1
+		{"1rk1r3/8/8/8/8/8/8/RK2R3 w EAeb - 0 1", WhiteOO, B1, G1, E1, F1, true},
+		{"1rk1r3/8/8/8/8/8/8/RK2R3 b EAeb - 0 1", BlackOOO, C8, C8, B8, D8, true},
+	}
+	for _, test := range tests {
+		b := MustParseFen(test.fen)
+		kf, kt, rf, rt, ok := b.CastlePath(test.wing)
+		if kf != test.kingFrom || kt != test.kingTo || rf != test.rookFrom || rt != test.rookTo || ok != test.ok {
+			t.Errorf("%s: CastlePath(%d) = %v %v %v %v %v, want %v %v %v %v %v", test.fen, test.wing,
+				kf, kt, rf, rt, ok, test.kingFrom, test.kingTo, test.rookFrom, test.rookTo, test.ok)
+		}
+	}
+}
+
+func TestCanCastleLegally(t *testing.T) {
+	tests := []struct {
+		fen     string
+		oo, ooo bool
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", true, true},
+		// the black rook attacks f1, which the king crosses
+		{"4kr2/8/8/8/8/8/8/R3K2R w KQ - 0 1", false, true},
+		// the king is in check
+		{"4r1k1/8/8/8/8/8/8/R3K2R w KQ - 0 1", false, false},
+		// the path is blocked
+		{"4k3/8/8/8/8/8/8/RN2K1NR w KQ - 0 1", false, false},
+		// b1 may be attacked when castling queenside
+		{"1r2k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", true, true},
+	}
+	for _, test := range tests {
+		b := MustParseFen(test.fen)
+		if got := b.CanCastleLegally(WhiteOO); got != test.oo {
+			t.Errorf("%s: CanCastleLegally(WhiteOO) = %v, want %v", test.fen, got, test.oo)
+		}
+		if got := b.CanCastleLegally(WhiteOOO); got != test.ooo {
+			t.Errorf("%s: CanCastleLegally(WhiteOOO) = %v, want %v", test.fen, got, test.ooo)
+		}
+	}
+}
+
+func TestCheckersAndPins(t *testing.T) {
+	tests := []struct {
+		name, fen        string
+		checkers, pinned []Sq
+	}{
+		{"start position", "", nil, nil},
+		// Nf6+ uncovers the rook on e1: double check
+		{"double check", "4k3/8/5N2/8/8/8/8/4R1K1 b - - 0 1", []Sq{E1, F6}, nil},
+		{"bishop pins knight", "r1bqkbnr/ppp2ppp/2np4/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 0 4",
+			nil, []Sq{C6}},
+		{"pinned knight", "4k3/8/8/8/8/2b5/3N4/4K3 w - - 0 1", nil, []Sq{D2}},
+		// two pieces between king and rook: no pin
+		{"no pin", "4k3/4r3/8/8/4N3/4P3/8/4K3 w - - 0 1", nil, nil},
+		// the rook does not pin along a diagonal
+		{"wrong line", "4k3/8/8/8/8/2r5/3N4/4K3 w - - 0 1", nil, nil},
+		{"check and pin", "4k3/8/8/b7/8/4r3/3Q4/4K3 w - - 0 1", []Sq{E3}, []Sq{D2}},
+	}
+	for _, test := range tests {
+		b := MustParseFen(test.fen)
+		if got := b.Checkers(); !reflect.DeepEqual(got, test.checkers) {
+			t.Errorf("%s: got checkers %v, want %v", test.name, got, test.checkers)
+		}
+		if got := b.PinnedPieces(); !reflect.DeepEqual(got, test.pinned) {
+			t.Errorf("%s: got pinned pieces %v, want %v", test.name, got, test.pinned)
+		}
+	}
+}
+
+func TestCheckmateStalemate(t *testing.T) {
+	tests := []struct {
+		fen                    string
+		check, mate, stalemate bool
+	}{
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", false, false, false},
+		{"R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", true, true, false}, // back-rank mate
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", false, false, true},   // stalemate
+		{"4k3/8/8/8/8/8/4R3/4K3 b - - 0 1", true, false, false},  // check
+	}
+	for _, test := range tests {
+		b := MustParseFen(test.fen)
+		if got := b.IsCheck(); got != test.check {
+			t.Errorf("%s: IsCheck() = %v, want %v", test.fen, got, test.check)
+		}
+		if got := b.IsCheckmate(); got != test.mate {
+			t.Errorf("%s: IsCheckmate() = %v, want %v", test.fen, got, test.mate)
+		}
+		if got := b.IsStalemate(); got != test.stalemate {
+			t.Errorf("%s: IsStalemate() = %v, want %v", test.fen, got, test.stalemate)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var h History
+	b := MustParseFen("")
+	h.Push(b)
+	shuffle := []Move{{G1, F3, NoPiece}, {G8, F6, NoPiece}, {F3, G1, NoPiece}, {F6, G8, NoPiece}}
+	for i := 0; i < 2; i++ {
+		if over, result := h.GameOver(b); over || result != "" {
+			t.Fatalf("game over after %d repetitions: %q", i, result)
+		}
+		for _, m := range shuffle {
+			b = b.MakeMove(m)
+			h.Push(b)
+		}
+	}
+	if !h.IsRepetition(b, 3) || h.IsRepetition(b, 4) {
+		t.Error("start position does not occur exactly three times")
+	}
+	if over, result := h.GameOver(b); !over || result != "1/2-1/2" {
+		t.Errorf("threefold repetition: got %v, %q, want draw", over, result)
+	}
+
+	h = History{}
+	b = MustParseFen("R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	h.Push(b)
+	if over, result := h.GameOver(b); !over || result != "1-0" {
+		t.Errorf("checkmate: got %v, %q, want 1-0", over, result)
+	}
+}
+
+func TestPawnStructure(t *testing.T) {
+	// White: isolated passed a-pawn, doubled (passed) c-pawns. Black: the
+	// e-pawn stops the white d- and f-pawns from being passed; the black
+	// h-pawn is passed.
+	b := MustParseFen("4k3/8/4p3/P7/3P1P1p/2P5/2P5/4K3 w - - 0 1")
+	tests := []struct {
+		sq                        Sq
+		passed, isolated, doubled bool
+	}{
+		{A5, true, true, false},
+		{D4, false, false, false},
+		{C3, true, false, true},
+		{C2, true, false, true},
+		{F4, false, true, false},
+		{E6, false, true, false},
+		{H4, true, true, false},
+		{E1, false, false, false}, // not a pawn
+	}
+	for _, test := range tests {
+		if got := b.IsPassedPawn(test.sq); got != test.passed {
+			t.Errorf("%v: IsPassedPawn() = %v, want %v", test.sq, got, test.passed)
+		}
+		if got := b.IsIsolated(test.sq); got != test.isolated {
+			t.Errorf("%v: IsIsolated() = %v, want %v", test.sq, got, test.isolated)
+		}
+		if got := b.IsDoubled(test.sq); got != test.doubled {
+			t.Errorf("%v: IsDoubled() = %v, want %v", test.sq, got, test.doubled)
+		}
+	}
+	// an isolated d-pawn
+	b = MustParseFen("4k3/pp3ppp/8/8/3P4/8/PP3PPP/4K3 w - - 0 1")
+	if !b.IsIsolated(D4) {
+		t.Error("d4 is not isolated")
+	}
+}
+
+func TestAttacksFrom(t *testing.T) {
+	b := MustParseFen("4k3/8/8/5p2/8/1P6/2B5/4K3 w - - 0 1")
+	tests := []struct {
+		sq      Sq
+		attacks []Sq
+	}{
+		// the bishop defends b3 and attacks f5, but not beyond
+		{C2, []Sq{B1, D1, B3, D3, E4, F5}},
+		// pawn attacks regardless of occupancy, not the push square
+		{B3, []Sq{A4, C4}},
+		{F5, []Sq{E4, G4}},
+		{E1, []Sq{D1, F1, D2, E2, F2}},
+		{A1, nil},
+	}
+	for _, test := range tests {
+		if got := b.AttacksFrom(test.sq); !reflect.DeepEqual(got, test.attacks) {
+			t.Errorf("%v: got %v, want %v", test.sq, got, test.attacks)
+		}
+	}
+}
+
+// Material
+
+func TestMaterial(t *testing.T) {
+	b := MustParseFen("")
+	if w, bl := b.Material(White), b.Material(Black); w != bl || w != 39 {
+		t.Errorf("start position material: white %d, black %d", w, bl)
+	}
+	if n := b.Count(WP); n != 8 {
+		t.Errorf("start position: want 8 white pawns, got %d", n)
+	}
+	if phase := b.Phase(); phase != MaxPhase {
+		t.Errorf("start position: want phase %d, got %d", MaxPhase, phase)
+	}
+	b = MustParseFen("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	if phase := b.Phase(); phase != 0 {
+		t.Errorf("bare kings: want phase 0, got %d", phase)
+	}
+}
+
+// Each
+
+func TestEach(t *testing.T) {
+	b := MustParseFen("")
+	n := 0
+	b.Each(func(sq Sq, p Piece) { n++ })
+	if n != 32 {
+		t.Errorf("Each: want 32 pieces, got %d", n)
+	}
+	n = 0
+	b.EachOf(Black, func(sq Sq, p Piece) {
+		if p.Color() != Black {
+			t.Errorf("EachOf(Black): got %c on %s", PieceLetters[p], sq)
+		}
+		n++
+	})
+	if n != 16 {
+		t.Errorf("EachOf: want 16 pieces, got %d", n)
+	}
+}
+
+func TestPieceMap(t *testing.T) {
+	start := MustParseFen("")
+	m := start.PieceMap()
+	if len(m) != 32 || m[E1] != WK || m[D8] != BQ {
+		t.Fatalf("got piece map %v", m)
+	}
+	if _, ok := m[E4]; ok {
+		t.Error("empty square in piece map")
+	}
+	b := FromPieceMap(m, White)
+	if got, want := b.Fen(), "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1"; got != want {
+		t.Errorf("FromPieceMap: got %q, want %q", got, want)
+	}
+	if b.Piece != start.Piece {
+		t.Error("FromPieceMap: pieces differ from the start position")
+	}
+}
+
+// SetPiece
+
+func TestSetPiece(t *testing.T) {
+	b := MustParseFen("r3k2r/8/8/8/3pP3/8/8/R3K2R b KQkq e3 0 1")
+	b.SetPiece(H1, NoPiece)
+	if sq := b.CastleSq[WhiteOO]; sq != NoSquare {
+		t.Errorf("rook removed from h1: want no castling, got %s", sq)
+	}
+	if sq := b.CastleSq[WhiteOOO]; sq != A1 {
+		t.Errorf("rook on a1 untouched: want castling with a1, got %s", sq)
+	}
+	b.SetPiece(E8, BQ)
+	if b.CastleSq[BlackOO] != NoSquare || b.CastleSq[BlackOOO] != NoSquare {
+		t.Errorf("king replaced on e8: want no black castling, got %v", b.CastleSq)
+	}
+	if b.EpSquare != E3 {
+		t.Errorf("want en-passant square e3, got %s", b.EpSquare)
+	}
+	b.SetPiece(E4, NoPiece)
+	if b.EpSquare != NoSquare {
+		t.Errorf("pawn removed from e4: want no en-passant square, got %s", b.EpSquare)
+	}
+}
+
+// Outcome
+
+type outcomeTest struct {
+	name     string
+	fen      string
+	finished bool
+	result   string
+}
+
+var outcomeTests = []outcomeTest{
+	{"start position", "", false, ""},
+	{"forty-nine moves", "4k3/8/8/8/8/8/4P3/4K3 w - - 99 80", false, ""},
+	{"fifty moves", "4k3/8/8/8/8/8/4P3/4K3 w - - 100 80", true, "1/2-1/2"},
+	{"seventy-five moves", "4k3/8/8/8/8/8/4P3/4K3 w - - 150 80", true, "1/2-1/2"},
+	{"fool's mate", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", true, "0-1"},
+	{"back rank mate", "3R2k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", true, "1-0"},
+	{"stalemate", "7k/5Q2/8/8/8/8/8/6K1 b - - 0 1", true, "1/2-1/2"},
+	{"white stalemated", "8/8/8/8/8/5k2/5p2/5K2 w - - 0 1", true, "1/2-1/2"},
+	{"bare kings", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true, "1/2-1/2"},
+	{"king and knight", "4k3/8/8/8/8/8/8/3NK3 w - - 0 1", true, "1/2-1/2"},
+	{"same colored bishops", "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true, "1/2-1/2"},
+	{"opposite colored bishops", "4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", false, ""},
+	{"two knights", "4k3/8/8/8/8/8/8/2NNK3 w - - 0 1", false, ""},
+}
+
+func TestOutcome(t *testing.T) {
+	for _, test := range outcomeTests {
+		finished, result := MustParseFen(test.fen).Outcome()
+		if finished != test.finished || result != test.result {
+			t.Errorf("%s: got %v, %q, want %v, %q", test.name, finished, result, test.finished, test.result)
+		}
+	}
+	b := MustParseFen("4k3/8/8/8/8/8/4P3/4K3 w - - 100 80")
+	if !b.FiftyMoveDraw() || b.SeventyFiveMoveDraw() {
+		t.Errorf("Rule50=100: got fifty-move draw %v, seventy-five-move draw %v",
+			b.FiftyMoveDraw(), b.SeventyFiveMoveDraw())
+	}
+}
@@ -13,6 +13,13 @@ var (
 	ErrTimeout = errors.New("timeout in engine communication")
 	// ErrExited indicates that the engine was closed.
 	ErrExited = errors.New("engine was closed")
+	// ErrEngineExited indicates that the engine process exited unexpectedly,
+	// for instance because it crashed. Errors reporting this wrap
+	// ErrEngineExited and the cause, such as the process's exit status.
+	ErrEngineExited = errors.New("engine exited unexpectedly")
+	// ErrInterrupted indicates that a search was cut short because the
+	// engine was quit.
+	ErrInterrupted = errors.New("search interrupted")
 )
 
 // Engine provides a generic interface to a running chess engine.
@@ -35,6 +42,10 @@ type Engine interface {
 	// given time.
 	SearchTime(t time.Duration) <-chan Info
 
+	// SearchNodes is like Search but tells the engine to stop after
+	// searching the given number of nodes.
+	SearchNodes(nodes int64) <-chan Info
+
 	// SearchClock is like Search but informs the engine of the time
 	// controls of the game and lets the engine decide how much time to
 	// use. movesToGo is the number of moves to the next time control.
@@ -43,8 +54,9 @@ type Engine interface {
 	// Stop stops a search started by one of the SearchXXX functions.
 	Stop()
 
-	// Quit quits the engine process.
-	Quit()
+	// Quit quits the engine process. It returns an error if the engine
+	// did not exit cleanly or the process could not be cleaned up.
+	Quit() error
 
 	// Ping pings the engine process to check that it is still responding.
 	Ping() error
@@ -61,14 +73,49 @@ type Pv struct {
 	Upperbound bool         // Score is a upperbound
 	Lowerbound bool         // Score is a lowerbound
 	Rank       int          // 0-based rank of the pv in a MultiPV search
+	Wdl        [3]int       // win/draw/loss chances in per mille, for white like Score; zero if not reported
+	SideToMove int          // side to move in the searched position; mated if Mate and Score is 0
+}
+
+// MateScore is the centipawn value WhiteScore gives a forced mate. Mate in n
+// moves scores MateScore-n, so that faster mates score higher.
+const MateScore = 100000
+
+// WhiteScore returns the score in centipawns from White's point of view:
+// positive is good for White. Mate scores are mapped to ±(MateScore-n) for a
+// mate in n moves, so that they compare correctly with centipawn scores. A
+// mate in 0 moves means that the side to move is mated.
+func (p *Pv) WhiteScore() int {
+	switch {
+	case !p.Mate:
+		return p.Score
+	case p.Score > 0 || p.Score == 0 && p.SideToMove == chess.Black:
+		return MateScore - p.Score
+	}
+	return -MateScore - p.Score
+}
+
+// MateIn returns the number of moves to mate, positive if White mates and
+// negative if Black mates, like Score. It returns !ok if the score is not a
+// mate score. A mate in 0 moves, reported when the side to move is already
+// mated, has no sign: SideToMove tells which side is mated.
+func (p *Pv) MateIn() (moves int, ok bool) {
+	if !p.Mate {
+		return 0, false
+	}
+	return p.Score, true
 }
 
 // Stats holds statistics from an engine search.
 type Stats struct {
-	Depth    int           // depth in plies
-	SelDepth int           // selective depth
-	Nodes    int           // number of nodes searched so far
-	Time     time.Duration // amount of time searched so far
+	Depth          int           // depth in plies
+	SelDepth       int           // selective depth
+	Nodes          int           // number of nodes searched so far
+	Time           time.Duration // amount of time searched so far
+	CurrMove       chess.Move    // move currently being searched
+	CurrMoveNumber int           // 1-based number of CurrMove in the move list
+	HashFull       int           // hash table fullness in per mille (0..1000)
+	Nps            int64         // nodes searched per second
 }
 
 // Info represents a generic information "event" sent over an Info channel
@@ -82,8 +129,9 @@ type Info interface {
 	// if no best move has been found yet.
 	BestMove() (move chess.Move, ok bool)
 
-	// Pv returns the principal variation of this Info. It can be nil if no
-	// pv information is available.
+	// Pv returns the principal variation of this Info. It is nil if the
+	// Info has no score. If the Info has a score but no moves, as when an
+	// engine reports a bound on failing high or low, Pv.Moves is empty.
 	Pv() *Pv
 
 	// Stats returns statistics of the search so far. Any of the Stats
@@ -121,3 +169,45 @@ type BoolOption interface {
 	SetBool(bool)  // change value
 	Default() bool // default value
 }
+
+// ButtonOption represents an option that triggers an action in the engine,
+// such as clearing the hash table.
+type ButtonOption interface {
+	Press() // trigger the action
+}
+
+// ComboOption represents an option that can be set to one of a list of
+// predefined values.
+type ComboOption interface {
+	Choices() []string // possible values
+	Get() string       // current value
+	Set(string)        // change value; must be one of Choices
+	Default() string   // default value
+}
+
+// CollectMultiPV reads Infos from infoc until the search ends and returns the
+// principal variations of a MultiPV search, ordered by rank. For each of the
+// first n ranks the last reported Pv is kept, so that deeper results replace
+// shallower ones; a bound without moves, as sent when the search fails high or
+// low, does not replace an earlier Pv. Ranks for which no Pv was reported are
+// nil. The error of a failed search is returned. n must be at least 1;
+// otherwise an error is returned without reading infoc.
+func CollectMultiPV(infoc <-chan Info, n int) ([]*Pv, error) {
+	if n < 1 {
+		return nil, errors.New("CollectMultiPV: n must be at least 1")
+	}
+	pvs := make([]*Pv, n)
+	for info := range infoc {
+		if err := info.Err(); err != nil {
+			return pvs, err
+		}
+		pv := info.Pv()
+		if pv == nil || pv.Rank >= n {
+			continue
+		}
+		if bound := pv.Upperbound || pv.Lowerbound; pvs[pv.Rank] == nil || len(pv.Moves) > 0 || !bound {
+			pvs[pv.Rank] = pv
+		}
+	}
+	return pvs, nil
+}
@@ -0,0 +1,123 @@
+// Package enginetest helps testing the engine protocol packages against fake
+// engines running in the test process.
+package enginetest
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/malbrecht/chess/engine"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Recorder records the commands received by a fake engine.
+type Recorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *Recorder) record(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+}
+
+// Commands returns the recorded commands that start with prefix.
+func (r *Recorder) Commands(prefix string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	for _, line := range r.lines {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Start starts a fake engine, which passes each command it receives to handle,
+// together with a channel for the engine's output lines. The fake engine
+// exits at the end of its input, or when handle returns false. Start returns
+// the engine's output and input, and the Recorder of its commands.
+func Start(handle func(cmd string, out chan<- string) bool) (stdout io.Reader, stdin *io.PipeWriter, rec *Recorder) {
+	r0, w0 := io.Pipe()
+	r1, w1 := io.Pipe()
+	rec = new(Recorder)
+	go serve(r1, w0, rec, handle)
+	return r0, w1, rec
+}
+
+func serve(r io.Reader, w io.WriteCloser, rec *Recorder, handle func(string, chan<- string) bool) {
+	// Write output asynchronously, like a real engine writing to a
+	// buffered pipe, so that commands are read while output is pending.
+	out := make(chan string, 64)
+	go func() {
+		for line := range out {
+			fmt.Fprintln(w, line)
+		}
+		w.Close()
+	}()
+	defer close(out)
+
+	buf := bufio.NewReader(r)
+	for {
+		line, _, err := buf.ReadLine()
+		if err != nil {
+			return
+		}
+		rec.record(string(line))
+		if !handle(string(line), out) {
+			return
+		}
+	}
+}
+
+// OptionTest describes an option of a fake engine.
+type OptionTest struct {
+	Decl    string      // declaration of the option by the fake engine
+	Name    string      // option name
+	Set     string      // value to set, if not empty
+	Value   interface{} // value after setting: string, int, bool or nil
+	Command string      // command sent by setting the option, if not empty
+}
+
+// CheckOptions sets the options of tests in opts and checks their values. It
+// then calls ping to make sure that the engine has received the commands, and
+// checks that rec recorded them.
+func CheckOptions(t *testing.T, opts map[string]engine.Option, tests []OptionTest, rec *Recorder, ping func() error) {
+	t.Helper()
+	for _, o := range tests {
+		opt := opts[o.Name]
+		if opt == nil {
+			t.Errorf("option %q not found", o.Name)
+			continue
+		}
+		if o.Set != "" {
+			opt.Set(o.Set)
+		}
+		switch want := o.Value.(type) {
+		case string:
+			if got := opt.String(); got != want {
+				t.Errorf("option %q: got %q, want %q", o.Name, got, want)
+			}
+		case int:
+			if got := opt.(engine.IntOption).Int(); got != want {
+				t.Errorf("option %q: got %d, want %d", o.Name, got, want)
+			}
+		case bool:
+			if got := opt.(engine.BoolOption).Bool(); got != want {
+				t.Errorf("option %q: got %v, want %v", o.Name, got, want)
+			}
+		}
+	}
+	if err := ping(); err != nil {
+		t.Fatal("ping:", err)
+	}
+	for _, o := range tests {
+		if o.Command != "" && len(rec.Commands(o.Command)) != 1 {
+			t.Errorf("option %q: command %q not sent", o.Name, o.Command)
+		}
+	}
+}
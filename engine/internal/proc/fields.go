@@ -0,0 +1,45 @@
+package proc
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fields splits a line into space-separated fields. Pos is the position in
+// Line following the fields read so far.
+type Fields struct {
+	Line string
+	Pos  int
+}
+
+// Tokenise returns the Fields of line.
+func Tokenise(line string) *Fields {
+	return &Fields{line, 0}
+}
+
+// Next returns the next field, or the empty string at the end of the line.
+func (f *Fields) Next() string {
+	notIsSpace := func(r rune) bool { return !unicode.IsSpace(r) }
+
+	l := f.Line[f.Pos:]
+	i := strings.IndexFunc(l, notIsSpace)
+	if i < 0 {
+		return ""
+	}
+	j := i + strings.IndexFunc(l[i:], unicode.IsSpace)
+	if j < i {
+		j = len(l)
+	}
+	f.Pos += j
+	return l[i:j]
+}
+
+// HasNext returns whether the end of the line has not been reached.
+func (f *Fields) HasNext() bool {
+	return f.Pos < len(f.Line)
+}
+
+// Remainder returns the rest of the line, with surrounding space removed.
+func (f *Fields) Remainder() string {
+	return strings.TrimSpace(f.Line[f.Pos:])
+}
@@ -0,0 +1,172 @@
+package proc
+
+import (
+	"fmt"
+	"github.com/malbrecht/chess/engine"
+	"strconv"
+	"strings"
+)
+
+// Setter sends the commands that change the values of options to the
+// communicator of an engine, which replies on errc.
+type Setter struct {
+	cmdc   chan<- interface{}
+	errc   <-chan error
+	format func(name string, value interface{}) string
+}
+
+// NewSetter returns a Setter sending commands on cmdc. format returns the
+// command setting the named option to value, which is a string, int or bool,
+// or the command pressing a button if value is nil.
+func NewSetter(cmdc chan<- interface{}, errc <-chan error, format func(name string, value interface{}) string) *Setter {
+	return &Setter{cmdc, errc, format}
+}
+
+func (s *Setter) set(name string, value interface{}) {
+	s.cmdc <- s.format(name, value)
+	<-s.errc
+}
+
+type option struct {
+	name   string
+	setter *Setter
+}
+
+type StringOption struct {
+	option
+	def   string
+	value string
+}
+
+// NewStringOption returns a string option with the given name and default.
+func NewStringOption(s *Setter, name, def string) *StringOption {
+	return &StringOption{option: option{name, s}, def: def, value: def}
+}
+
+func (s *StringOption) StringDefault() string { return s.def }
+func (s *StringOption) String() string        { return s.value }
+func (s *StringOption) Set(value string) {
+	s.value = value
+	s.setter.set(s.name, s.value)
+}
+
+type IntOption struct {
+	option
+	def   int
+	value int
+	min   int
+	max   int
+}
+
+// NewIntOption returns a number option with the given name, default and
+// range.
+func NewIntOption(s *Setter, name string, def, min, max int) *IntOption {
+	return &IntOption{option: option{name, s}, def: def, value: def, min: min, max: max}
+}
+
+func (i *IntOption) StringDefault() string { return fmt.Sprint(i.def) }
+func (i *IntOption) String() string        { return fmt.Sprint(i.value) }
+func (i *IntOption) Default() int          { return i.def }
+func (i *IntOption) Int() int              { return i.value }
+func (i *IntOption) Min() int              { return i.min }
+func (i *IntOption) Max() int              { return i.max }
+
+func (i *IntOption) Set(value string) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		panic(err)
+	}
+	i.SetInt(v)
+}
+
+func (i *IntOption) SetInt(v int) {
+	i.value = v
+	i.setter.set(i.name, i.value)
+}
+
+type BoolOption struct {
+	option
+	def   bool
+	value bool
+}
+
+// NewBoolOption returns a check option with the given name and default.
+func NewBoolOption(s *Setter, name string, def bool) *BoolOption {
+	return &BoolOption{option: option{name, s}, def: def, value: def}
+}
+
+func (b *BoolOption) StringDefault() string { return fmt.Sprint(b.def) }
+func (b *BoolOption) String() string        { return fmt.Sprint(b.value) }
+func (b *BoolOption) Default() bool         { return b.def }
+func (b *BoolOption) Bool() bool            { return b.value }
+
+func (b *BoolOption) Set(value string) {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		panic(err)
+	}
+	b.SetBool(v)
+}
+
+func (b *BoolOption) SetBool(v bool) {
+	b.value = v
+	b.setter.set(b.name, b.value)
+}
+
+type ComboOption struct {
+	option
+	def     string
+	value   string
+	choices []string
+}
+
+// NewComboOption returns a combo option with the given name, default and
+// choices.
+func NewComboOption(s *Setter, name, def string, choices []string) *ComboOption {
+	return &ComboOption{option: option{name, s}, def: def, value: def, choices: choices}
+}
+
+func (c *ComboOption) StringDefault() string { return c.def }
+func (c *ComboOption) String() string        { return c.value }
+func (c *ComboOption) Default() string       { return c.def }
+func (c *ComboOption) Get() string           { return c.value }
+func (c *ComboOption) Choices() []string     { return c.choices }
+
+// Set changes the value of the option. It panics if value is not one of the
+// option's choices (compared case-insensitively).
+func (c *ComboOption) Set(value string) {
+	for _, choice := range c.choices {
+		if strings.EqualFold(choice, value) {
+			c.value = choice
+			c.setter.set(c.name, c.value)
+			return
+		}
+	}
+	panic(fmt.Sprintf("option %s: invalid value %q", c.name, value))
+}
+
+// ButtonOption has no value: its String and StringDefault methods return
+// the empty string, and Set presses the button, ignoring the value.
+type ButtonOption struct {
+	option
+}
+
+// NewButtonOption returns a button option with the given name.
+func NewButtonOption(s *Setter, name string) *ButtonOption {
+	return &ButtonOption{option{name, s}}
+}
+
+func (b *ButtonOption) StringDefault() string { return "" }
+func (b *ButtonOption) String() string        { return "" }
+func (b *ButtonOption) Set(string)            { b.Press() }
+
+// Press triggers the action of the button.
+func (b *ButtonOption) Press() {
+	b.setter.set(b.name, nil)
+}
+
+var _ engine.StringOption = &StringOption{}
+var _ engine.BoolOption = &BoolOption{}
+var _ engine.IntOption = &IntOption{}
+var _ engine.ComboOption = &ComboOption{}
+var _ engine.ButtonOption = &ButtonOption{}
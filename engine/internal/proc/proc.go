@@ -0,0 +1,118 @@
+// Package proc holds the plumbing shared by the engine protocol packages:
+// running the engine process, exchanging lines of text with it, splitting
+// them into fields, and the engine options.
+package proc
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/malbrecht/chess/engine"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Process implements io.Closer for a running process.
+type Process struct {
+	cmd  *exec.Cmd
+	wait time.Duration
+}
+
+// Start starts an engine executable, with the given arguments, and returns
+// the process and pipes connected to its standard output and input. Closing
+// the process waits up to wait for it to exit before killing it.
+func Start(exe string, args []string, wait time.Duration) (p *Process, stdout io.Reader, stdin io.Writer, err error) {
+	cmd := exec.Command(exe, args...)
+	stdin, err = cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("start engine: %s", err)
+	}
+	stdout, err = cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("start engine: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s %v: %s", exe, args, err)
+	}
+	return &Process{cmd, wait}, stdout, stdin, nil
+}
+
+// SetWait sets the time Close waits for the process to exit before killing
+// it.
+func (p *Process) SetWait(d time.Duration) {
+	p.wait = d
+}
+
+// Close waits for the process to stop, returning the error reported by
+// exec.Cmd.Wait, such as a non-zero exit status.
+func (p *Process) Close() error {
+	if p.cmd == nil {
+		return nil
+	}
+	waited := make(chan error)
+	go func() {
+		waited <- p.cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-waited:
+		// nothing
+	case <-time.After(p.wait):
+		p.cmd.Process.Kill()
+		err = <-waited
+	}
+	p.cmd = nil
+	return err
+}
+
+// ReadLines sends the lines read from stdout, with surrounding space removed,
+// on linec. Overlong lines are cut short. At the end of the input, linec is
+// closed and the error that ended reading is stored in *perr.
+func ReadLines(stdout io.Reader, linec chan<- string, perr *error) {
+	bufrd := bufio.NewReader(stdout)
+	for {
+		line, isprefix, err := bufrd.ReadLine()
+		for err == nil && isprefix {
+			// ignore rest of line
+			_, isprefix, err = bufrd.ReadLine()
+		}
+		if err != nil {
+			*perr = err
+			break
+		}
+		linec <- strings.TrimSpace(string(line))
+	}
+	close(linec)
+}
+
+// WriteLine writes line to w, followed by a newline. It returns
+// engine.ErrTimeout if the write does not complete in time.
+func WriteLine(w io.Writer, line string, timeout time.Duration) error {
+	errc := make(chan error)
+	go func() {
+		_, err := fmt.Fprintln(w, line)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(timeout):
+		return engine.ErrTimeout
+	}
+}
+
+// ExitError closes the process p of an engine that exited unexpectedly, and
+// returns the error to report: engine.ErrEngineExited, wrapping the error
+// from closing the process or else readError, the error that ended
+// ReadLines, if it is not io.EOF.
+func ExitError(p io.Closer, readError error) error {
+	cause := p.Close()
+	if cause == nil && readError != io.EOF {
+		cause = readError
+	}
+	if cause == nil {
+		return engine.ErrEngineExited
+	}
+	return fmt.Errorf("%w: %v", engine.ErrEngineExited, cause)
+}
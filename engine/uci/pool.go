@@ -0,0 +1,125 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Pool maintains a fixed set of running engines that can be shared, avoiding
+// the cost of starting an engine for every search.
+type Pool struct {
+	start   func() (*Engine, error)
+	size    int
+	free    chan *Engine
+	dropped chan struct{} // a token for each engine that could not be replaced
+
+	mu      sync.Mutex
+	options map[*Engine]map[string]string // option values at start-up
+}
+
+// NewPool starts size instances of an engine executable, with the given
+// arguments. size must be at least 1.
+func NewPool(exe string, args []string, size int) (*Pool, error) {
+	return newPool(size, func() (*Engine, error) {
+		return Run(exe, args, nil)
+	})
+}
+
+func newPool(size int, start func() (*Engine, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, errors.New("NewPool: size must be at least 1")
+	}
+	p := &Pool{
+		start:   start,
+		size:    size,
+		free:    make(chan *Engine, size),
+		dropped: make(chan struct{}, size),
+		options: make(map[*Engine]map[string]string),
+	}
+	var engines []*Engine
+	for i := 0; i < size; i++ {
+		e, err := start()
+		if err != nil {
+			for _, e := range engines {
+				e.Quit()
+			}
+			return nil, err
+		}
+		engines = append(engines, e)
+		p.add(e)
+	}
+	return p, nil
+}
+
+// Acquire takes an engine from the pool, waiting for one to be released if all
+// engines are in use. It returns ctx.Err() if ctx is done before an engine
+// becomes available.
+func (p *Pool) Acquire(ctx context.Context) (*Engine, error) {
+	select {
+	case e := <-p.free:
+		return e, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release returns an engine obtained from Acquire to the pool. Any search must
+// have finished before the engine is released. The engine is made clean for
+// its next user: it is sent "ucinewgame", and the changes made through the
+// Engine's methods are undone, see below. An engine that no longer responds,
+// for instance because it crashed, is quit and replaced by a newly started
+// one. If that fails, the pool shrinks by one engine and the error is
+// returned.
+//
+// The options are restored to their values at start-up, the chess960 and
+// automatic ucinewgame modes are switched off, the position is cleared and
+// the timeouts are reset to the defaults.
+func (p *Pool) Release(e *Engine) error {
+	e.NewGame()
+	if err := e.Ping(); err == nil {
+		p.mu.Lock()
+		options := p.options[e]
+		p.mu.Unlock()
+		e.reset(options)
+		p.free <- e
+		return nil
+	}
+	e.Quit()
+	p.mu.Lock()
+	delete(p.options, e)
+	p.mu.Unlock()
+	e, err := p.start()
+	if err != nil {
+		p.dropped <- struct{}{}
+		return fmt.Errorf("replacing engine: %w", err)
+	}
+	p.add(e)
+	return nil
+}
+
+// add adds a newly started engine to the free engines, saving its options for
+// resetting it on release.
+func (p *Pool) add(e *Engine) {
+	p.mu.Lock()
+	p.options[e] = e.SaveOptions()
+	p.mu.Unlock()
+	p.free <- e
+}
+
+// Close quits all engines in the pool, waiting for acquired engines to be
+// released. It returns the first error from quitting an engine.
+func (p *Pool) Close() error {
+	var err error
+	for i := 0; i < p.size; i++ {
+		select {
+		case e := <-p.free:
+			if qerr := e.Quit(); err == nil {
+				err = qerr
+			}
+		case <-p.dropped:
+		}
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/engine/internal/enginetest"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	const size = 2
+	recs := make(map[*Engine]*enginetest.Recorder)
+	pool, err := newPool(size, func() (*Engine, error) {
+		e, rec := startFakeEngine(t)
+		recs[e] = rec
+		return e, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	var engines []*Engine
+	for i := 0; i < size; i++ {
+		e, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal("acquire:", err)
+		}
+		engines = append(engines, e)
+	}
+
+	// all engines are busy: acquiring times out...
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if e, err := pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, %v, want %v", e, err, context.DeadlineExceeded)
+	}
+
+	// ...or blocks until an engine is released
+	acquired := make(chan *Engine)
+	go func() {
+		e, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Error("acquire:", err)
+		}
+		acquired <- e
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired more than the pool size")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pool.Release(engines[0])
+	select {
+	case e := <-acquired:
+		if e != engines[0] {
+			t.Error("acquired an engine that was not released")
+		}
+		engines[0] = e
+	case <-time.After(CommunicationTimeout):
+		t.Fatal("acquire blocked after release")
+	}
+	engines[0].Ping() // make sure that the fake engine has processed the command
+	if got := recs[engines[0]].Commands("ucinewgame"); len(got) != 1 {
+		t.Errorf("got %d ucinewgame commands on release, want 1", len(got))
+	}
+
+	for _, e := range engines {
+		if err := pool.Release(e); err != nil {
+			t.Error("release:", err)
+		}
+	}
+}
+
+func TestPoolReset(t *testing.T) {
+	pool, err := newPool(1, func() (*Engine, error) {
+		e, _ := startFakeEngine(t)
+		return e, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	e, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal("acquire:", err)
+	}
+	e.Options()["number option 1"].Set("8")
+	e.SetChess960(true)
+	e.SetAutoNewGame(true)
+	e.SetPosition(chess.MustParseFen(""))
+	if err := pool.Release(e); err != nil {
+		t.Fatal("release:", err)
+	}
+
+	if e, err = pool.Acquire(context.Background()); err != nil {
+		t.Fatal("acquire:", err)
+	}
+	defer pool.Release(e)
+	opts := e.Options()
+	if got := opts["number option 1"].String(); got != "5" {
+		t.Errorf("number option 1 = %s after release, want 5", got)
+	}
+	if got := opts["UCI_Chess960"].String(); got != "false" {
+		t.Errorf("UCI_Chess960 = %s after release, want false", got)
+	}
+	if e.chess960 || e.autoNewGame {
+		t.Errorf("chess960 = %v, autoNewGame = %v after release, want false", e.chess960, e.autoNewGame)
+	}
+	if e.board != nil {
+		t.Error("position still set after release")
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		if _, err := NewPool("engine", nil, size); err == nil {
+			t.Errorf("NewPool with size %d: got no error", size)
+		}
+	}
+}
+
+func TestPoolReplace(t *testing.T) {
+	errStart := errors.New("cannot start engine")
+	var failStart bool
+	pool, err := newPool(1, func() (*Engine, error) {
+		if failStart {
+			return nil, errStart
+		}
+		e, _ := startFakeEngine(t)
+		return e, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crash acquires an engine from the pool and makes it exit
+	crash := func() *Engine {
+		e, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal("acquire:", err)
+		}
+		e.SetPosition(chess.MustParseFen(""))
+		drain(e.search("go crash"))
+		return e
+	}
+
+	// a crashed engine is replaced
+	dead := crash()
+	if err := pool.Release(dead); err != nil {
+		t.Fatal("release:", err)
+	}
+	e, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal("acquire:", err)
+	}
+	if e == dead {
+		t.Error("acquired the crashed engine")
+	}
+	if err := e.Ping(); err != nil {
+		t.Error("replacement engine:", err)
+	}
+	pool.Release(e)
+
+	// if it cannot be replaced, the error is reported and the pool shrinks
+	failStart = true
+	if err := pool.Release(crash()); !errors.Is(err, errStart) {
+		t.Errorf("release: got %v, want %v", err, errStart)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if e, err := pool.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, %v from an empty pool, want %v", e, err, context.DeadlineExceeded)
+	}
+	if err := pool.Close(); err != nil {
+		t.Error("close:", err)
+	}
+}
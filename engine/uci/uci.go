@@ -3,54 +3,35 @@
 package uci
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/malbrecht/chess"
 	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/engine/internal/proc"
 	"io"
 	"log"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 )
 
 // CommunicationTimeout is the time to wait for a response from the engine. If
-// the engine fails to respond, it is terminated.
+// the engine fails to respond, it is terminated. It is the default for engines
+// started by Run after it is set; use RunTimeout to start an engine with a
+// different timeout and Engine.SetTimeout to change the timeout of a running
+// engine.
 var CommunicationTimeout time.Duration = 3 * time.Second
 
-// process implements io.Closer for a running process.
-type process struct {
-	cmd *exec.Cmd
-}
-
-// Close waits for the process to stop.
-func (p *process) Close() error {
-	if p.cmd == nil {
-		return nil
-	}
-	waited := make(chan bool)
-	go func() {
-		p.cmd.Wait()
-		waited <- true
-	}()
-	select {
-	case <-waited:
-		// nothing
-	case <-time.After(CommunicationTimeout):
-		p.cmd.Process.Kill()
-		<-waited
-	}
-	p.cmd = nil
-	return nil
-}
-
 // Engine represents a running UCI engine.
 type Engine struct {
-	cmdc chan<- interface{}
-	errc <-chan error
+	cmdc        chan<- interface{}
+	errc        <-chan error
+	stopc       chan<- chan engine.Info // for stopping a search, see stopSearch
+	board       *chess.Board            // position set by SetPosition
+	chess960    bool                    // send positions in Shredder-FEN
+	autoNewGame bool                    // send ucinewgame in SetPosition
+	timeout     time.Duration           // communication timeout at start-up
 }
 
 var _ engine.Engine = &Engine{}
@@ -58,41 +39,44 @@ var _ engine.Engine = &Engine{}
 // Run starts an engine executable, with the given arguments. If logger is not
 // nil, it will be used to log all communication to and from the engine.
 func Run(exe string, args []string, logger *log.Logger) (*Engine, error) {
-	cmd := exec.Command(exe, args...)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("start engine: %s", err)
-	}
-	stdout, err := cmd.StdoutPipe()
+	return RunTimeout(exe, args, logger, CommunicationTimeout)
+}
+
+// RunTimeout is like Run, but the engine uses timeout instead of
+// CommunicationTimeout, from its start-up to its exit.
+func RunTimeout(exe string, args []string, logger *log.Logger, timeout time.Duration) (*Engine, error) {
+	p, stdout, stdin, err := proc.Start(exe, args, timeout)
 	if err != nil {
-		return nil, fmt.Errorf("start engine: %s", err)
-	}
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("%s %v: %s", exe, args, err)
+		return nil, err
 	}
-	return initialise(stdout, stdin, &process{cmd}, logger)
+	return initialise(stdout, stdin, p, logger, timeout)
 }
 
-func initialise(stdout io.Reader, stdin io.Writer, proc io.Closer, logger *log.Logger) (*Engine, error) {
+func initialise(stdout io.Reader, stdin io.Writer, process io.Closer, logger *log.Logger, timeout time.Duration) (*Engine, error) {
 	var (
 		cmdc  = make(chan interface{})
 		errc  = make(chan error)
+		stopc = make(chan chan engine.Info)
 		linec = make(chan string)
 	)
 	c := &comm{
 		cmdc:    cmdc,
 		errc:    errc,
+		stopc:   stopc,
 		linec:   linec,
 		stdin:   stdin,
-		process: proc,
+		process: process,
 		log:     logger,
+		wait:    timeout,
 	}
 	go c.run()
-	go readLines(stdout, linec, &c.readError)
+	go proc.ReadLines(stdout, linec, &c.readError)
 
 	e := &Engine{
-		cmdc: cmdc,
-		errc: errc,
+		cmdc:    cmdc,
+		errc:    errc,
+		stopc:   stopc,
+		timeout: c.wait,
 	}
 	if err := e.Send("uci"); err != nil {
 		return nil, err
@@ -116,20 +100,172 @@ func (e *Engine) Ping() error {
 	return e.Send("isready")
 }
 
-// Quit implements engine.Engine.
-func (e *Engine) Quit() {
-	e.Send("quit")
+// SetTimeout sets the time to wait for a response from the engine, replacing
+// the timeout the engine was started with. If the engine fails to respond in
+// time, it is terminated. It is also the time Quit waits for the engine to
+// exit before killing it.
+func (e *Engine) SetTimeout(d time.Duration) {
+	e.cmdc <- d
+	<-e.errc
+}
+
+// SetSearchTimeout sets how long a search may go without output from the
+// engine. If neither info nor bestmove arrives in time, the engine is
+// considered stuck: it is terminated and the search ends with an Info
+// reporting engine.ErrTimeout. Engines normally report their progress at
+// least every second, but some are silent while waiting for the stop of an
+// infinite search that has already finished, so d should be generous. The
+// default, 0, means no timeout.
+func (e *Engine) SetSearchTimeout(d time.Duration) {
+	e.cmdc <- searchTimeout(d)
+	<-e.errc
+}
+
+// Quit implements engine.Engine. A search that is still running ends with an
+// Info reporting engine.ErrInterrupted. Quit returns the error, if any, of
+// closing the engine process, or an earlier communication error.
+func (e *Engine) Quit() error {
+	err := e.Send("quit")
 	close(e.cmdc)
+	return err
 }
 
 // Search
 
-// SetPosition implements engine.Engine.
-func (e *Engine) SetPosition(board *chess.Board) {
+// NewGame tells the engine that the next position is from a different game,
+// by sending "ucinewgame". The engine may clear its hash tables and other
+// state kept between searches.
+func (e *Engine) NewGame() {
 	e.Send("ucinewgame")
-	e.Send(fmt.Sprintf("position fen %s", board.Fen()))
+}
+
+// SetAutoNewGame controls whether SetPosition calls NewGame before sending
+// the position, as it did by default in earlier versions. This throws away
+// the engine's state, which helps when analysing unrelated positions but
+// makes searches of consecutive positions of a game slower.
+func (e *Engine) SetAutoNewGame(on bool) {
+	e.autoNewGame = on
+}
+
+// SetPosition implements engine.Engine. It only sends the position; call
+// NewGame first when starting a new game, or use SetAutoNewGame.
+func (e *Engine) SetPosition(board *chess.Board) {
+	e.setPosition(fmt.Sprintf("position fen %s", e.fen(board)), board)
+}
+
+// SetPositionMoves sets the position to search to the position after playing
+// moves from start. The moves are sent to the engine, which lets it detect
+// repetitions, and the position is sent as "startpos" if start is the
+// standard starting position, as some engines handle that better than a FEN.
+func (e *Engine) SetPositionMoves(start *chess.Board, moves []chess.Move) {
+	cmd := "position startpos"
+	if start.Fen() != chess.MustParseFen("").Fen() {
+		cmd = "position fen " + e.fen(start)
+	}
+	board := start
+	if len(moves) > 0 {
+		cmd += " moves"
+		for _, m := range moves {
+			cmd += " " + e.uci(m, board)
+			board = board.MakeMove(m)
+		}
+	}
+	e.setPosition(cmd, board)
+}
+
+// setPosition sends a position command, and tells the communicator the
+// resulting board, for parsing the engine's moves.
+func (e *Engine) setPosition(cmd string, board *chess.Board) {
+	if e.autoNewGame {
+		e.NewGame()
+	}
+	e.Send(cmd)
 	e.cmdc <- board
 	<-e.errc
+	e.board = board
+}
+
+// fen returns the FEN of board as understood by the engine.
+func (e *Engine) fen(board *chess.Board) string {
+	if e.chess960 {
+		return board.ShredderFen()
+	}
+	return board.Fen()
+}
+
+// uci returns m in UCI notation as understood by the engine. Castling is
+// written as king-takes-own-rook (e1h1) in chess960 mode only; otherwise the
+// king moves two squares (e1g1), which is what standard engines expect.
+func (e *Engine) uci(m chess.Move, board *chess.Board) string {
+	king, rook := board.Piece[m.From], board.Piece[m.To]
+	if !e.chess960 && king.Type() == chess.King && rook.Type() == chess.Rook && king.Color() == rook.Color() {
+		file := 6 // g-file
+		if m.To < m.From {
+			file = 2 // c-file
+		}
+		m.To = chess.Square(file, m.From.Rank())
+	}
+	return m.Uci(board)
+}
+
+// Ponder starts pondering on the position set by SetPosition after the
+// opponent's expected reply ponderMove. The engine searches until PonderHit
+// or Stop is called. The returned channel behaves like that of a normal
+// search, with moves relative to the position after ponderMove.
+func (e *Engine) Ponder(ponderMove chess.Move) <-chan engine.Info {
+	if e.board == nil {
+		return e.search("go ponder") // reports the missing position
+	}
+	e.Send(fmt.Sprintf("position fen %s moves %s", e.fen(e.board), e.uci(ponderMove, e.board)))
+	e.cmdc <- e.board.MakeMove(ponderMove)
+	<-e.errc
+	return e.search("go ponder")
+}
+
+// PonderHit tells the engine that the opponent played the expected move,
+// turning the ponder search into a regular search.
+func (e *Engine) PonderHit() {
+	e.Send("ponderhit")
+}
+
+// Capabilities describes optional features of an engine. UCI has no way to
+// advertise them directly, so they are derived from the engine's options.
+type Capabilities struct {
+	MultiPV       bool // several principal variations ("MultiPV")
+	Chess960      bool // chess960 positions ("UCI_Chess960")
+	Ponder        bool // pondering ("Ponder")
+	LimitStrength bool // playing at reduced strength ("UCI_LimitStrength")
+	ShowWDL       bool // win/draw/loss statistics ("UCI_ShowWDL")
+}
+
+// Capabilities returns the optional features the engine supports, judging by
+// the options it advertises.
+func (e *Engine) Capabilities() Capabilities {
+	opts := e.Options()
+	has := func(name string) bool {
+		_, ok := opts[name]
+		return ok
+	}
+	return Capabilities{
+		MultiPV:       has("MultiPV"),
+		Chess960:      has("UCI_Chess960"),
+		Ponder:        has("Ponder"),
+		LimitStrength: has("UCI_LimitStrength"),
+		ShowWDL:       has("UCI_ShowWDL"),
+	}
+}
+
+// SetChess960 switches chess960 mode on or off, setting the engine's
+// UCI_Chess960 option if it has one. In chess960 mode positions are sent with
+// the castling rights in Shredder-FEN style (HAha), naming the files of the
+// castling rooks, and castling moves are sent as king-takes-own-rook (e1h1)
+// rather than as the king moving two squares (e1g1). Castling moves from the
+// engine are understood in either form.
+func (e *Engine) SetChess960(on bool) {
+	if opt, ok := e.Options()["UCI_Chess960"].(*BoolOption); ok {
+		opt.SetBool(on)
+	}
+	e.chess960 = on
 }
 
 // Search implements engine.Engine.
@@ -149,6 +285,12 @@ func (e *Engine) SearchTime(t time.Duration) <-chan engine.Info {
 	return e.search(fmt.Sprintf(cmd, t/time.Millisecond))
 }
 
+// SearchNodes implements engine.Engine.
+func (e *Engine) SearchNodes(nodes int64) <-chan engine.Info {
+	cmd := "go nodes %d"
+	return e.search(fmt.Sprintf(cmd, nodes))
+}
+
 // SearchClock implements engine.Engine.
 func (e *Engine) SearchClock(wtime, btime, winc, binc time.Duration, movesToGo int) <-chan engine.Info {
 	return e.search(fmt.Sprintf(
@@ -158,7 +300,117 @@ func (e *Engine) SearchClock(wtime, btime, winc, binc time.Duration, movesToGo i
 		movesToGo))
 }
 
-func (e *Engine) search(cmd string) <-chan engine.Info {
+// SearchContext is like Search, but the search is stopped when ctx is
+// cancelled or its deadline passes. The channel still delivers the final
+// Info with the best move before it is closed.
+func (e *Engine) SearchContext(ctx context.Context) <-chan engine.Info {
+	infoc := e.search("go infinite")
+	outc := make(chan engine.Info, 1)
+	go func() {
+		done := ctx.Done()
+		var stopc chan<- chan engine.Info // set when ctx is done, see stopSearch
+		for {
+			select {
+			case info, ok := <-infoc:
+				if !ok {
+					close(outc)
+					return
+				}
+				outc <- info
+			case <-done:
+				stopc, done = e.stopc, nil
+			case stopc <- infoc:
+				stopc = nil
+			}
+		}
+	}()
+	return outc
+}
+
+// SearchLimit specifies when a search started by SearchMoves should stop.
+// Limits that are zero are not applied; the search is infinite if Infinite is
+// set or if no limit is given.
+type SearchLimit struct {
+	Depth    int           // maximum depth in plies
+	Time     time.Duration // time to search
+	Nodes    int64         // maximum number of nodes
+	Infinite bool          // search until Stop is called
+}
+
+// args returns the arguments to the go command for the limit.
+func (l SearchLimit) args() string {
+	var args []string
+	if l.Depth > 0 {
+		args = append(args, fmt.Sprintf("depth %d", l.Depth))
+	}
+	if l.Time > 0 {
+		args = append(args, fmt.Sprintf("movetime %d", l.Time/time.Millisecond))
+	}
+	if l.Nodes > 0 {
+		args = append(args, fmt.Sprintf("nodes %d", l.Nodes))
+	}
+	if l.Infinite || len(args) == 0 {
+		args = []string{"infinite"}
+	}
+	return strings.Join(args, " ")
+}
+
+// SearchMoves searches the position set by SetPosition, restricted to the
+// given moves, until limit is reached.
+func (e *Engine) SearchMoves(moves []chess.Move, limit SearchLimit) <-chan engine.Info {
+	cmd := "go " + limit.args()
+	if e.board == nil {
+		return e.search(cmd) // reports the missing position
+	}
+	if len(moves) > 0 {
+		cmd += " searchmoves"
+		for _, m := range moves {
+			cmd += " " + e.uci(m, e.board)
+		}
+	}
+	return e.search(cmd)
+}
+
+// BestMove searches board for duration d and returns the best move found,
+// together with the last principal variation reported. If ctx is done before
+// the search has finished, the search is stopped and ctx.Err() is returned.
+func (e *Engine) BestMove(ctx context.Context, board *chess.Board, d time.Duration) (chess.Move, *engine.Pv, error) {
+	e.SetPosition(board)
+	infoc := e.search(fmt.Sprintf("go movetime %d", d/time.Millisecond)) // as SearchTime
+	var pv *engine.Pv
+	done := ctx.Done()
+	var stopc chan<- chan engine.Info // set when ctx is done, see stopSearch
+	for {
+		select {
+		case info, ok := <-infoc:
+			if !ok {
+				return chess.NullMove, pv, errors.New("search ended without a best move")
+			}
+			if err := info.Err(); err != nil {
+				return chess.NullMove, pv, err
+			}
+			// keep the last line with moves over a bound without
+			// moves, as sent when the search fails high or low
+			if p := info.Pv(); p != nil && (pv == nil || len(p.Moves) > 0 || !p.Upperbound && !p.Lowerbound) {
+				pv = p
+			}
+			if m, ok := info.BestMove(); ok {
+				if err := ctx.Err(); err != nil {
+					return chess.NullMove, pv, err
+				}
+				return m, pv, nil
+			}
+		case <-done:
+			stopc, done = e.stopc, nil
+		case stopc <- infoc:
+			stopc = nil
+		}
+	}
+}
+
+// search starts a search with the given go command. The returned channel can
+// also be sent on stopc to stop the search, see stopSearch.
+func (e *Engine) search(cmd string) chan engine.Info {
 	infoc := make(chan engine.Info, 1)
 	if err := e.initSearch(cmd, infoc); err != nil {
 		infoc <- Info{err: err}
@@ -180,7 +432,9 @@ func (e *Engine) initSearch(cmd string, infoc chan engine.Info) error {
 	return nil
 }
 
-// Options implements engine.Engine.
+// Options implements engine.Engine. Options that the engine advertises again
+// after initialisation replace the earlier ones in the maps returned by later
+// calls; maps returned before are not changed.
 func (e *Engine) Options() map[string]engine.Option {
 	optc := make(chan map[string]engine.Option)
 	e.cmdc <- optc
@@ -190,11 +444,55 @@ func (e *Engine) Options() map[string]engine.Option {
 	return <-optc
 }
 
+// SaveOptions returns the current values of the engine's options, by name, for
+// use with RestoreOptions. Button options have no value and are left out.
+func (e *Engine) SaveOptions() map[string]string {
+	values := make(map[string]string)
+	for name, opt := range e.Options() {
+		if _, ok := opt.(*ButtonOption); !ok {
+			values[name] = opt.String()
+		}
+	}
+	return values
+}
+
+// RestoreOptions sets the engine's options to the values saved by
+// SaveOptions. Only options whose value has changed are sent to the engine.
+// Unknown options are ignored.
+func (e *Engine) RestoreOptions(values map[string]string) {
+	options := e.Options()
+	for name, value := range values {
+		opt, ok := options[name]
+		if !ok || opt.String() == value {
+			continue
+		}
+		if _, ok := opt.(*ButtonOption); !ok {
+			opt.Set(value)
+		}
+	}
+}
+
+// reset returns the engine to the state it was started in: the options are
+// restored to the values saved by SaveOptions at start-up, the modes set by
+// SetChess960 and SetAutoNewGame are off, no position is set, and the timeouts
+// are the defaults.
+func (e *Engine) reset(options map[string]string) {
+	e.RestoreOptions(options)
+	e.chess960 = false
+	e.autoNewGame = false
+	e.SetTimeout(e.timeout)
+	e.SetSearchTimeout(0)
+	e.cmdc <- (*chess.Board)(nil)
+	<-e.errc
+	e.board = nil
+}
+
 // Communicator.
 
 type comm struct {
 	cmdc      chan interface{}         // request channel
 	errc      chan error               // response channel
+	stopc     chan chan engine.Info    // stop requests, see stopSearch
 	err       error                    // error state of the communication
 	linec     <-chan string            // engine output lines
 	infoc     chan<- engine.Info       // for sending out "info ..." lines
@@ -206,53 +504,42 @@ type comm struct {
 	author    string                   // engine author(s)
 	options   map[string]engine.Option // engine options
 	readError error                    // error returned by readLines
+	wait      time.Duration            // communication timeout
+	idleWait  time.Duration            // search inactivity timeout, 0 for none
 }
 
-func readLines(stdout io.Reader, linec chan<- string, perr *error) {
-	bufrd := bufio.NewReader(stdout)
-	for {
-		line, isprefix, err := bufrd.ReadLine()
-		for err == nil && isprefix {
-			// ignore rest of line
-			_, isprefix, err = bufrd.ReadLine()
-		}
-		if err != nil {
-			*perr = err
-			break
-		}
-		linec <- strings.TrimSpace(string(line))
-	}
-	close(linec)
-}
-
-func timeoutWrite(w io.Writer, line string) error {
-	errc := make(chan error)
-	go func() {
-		_, err := fmt.Fprintln(w, line)
-		errc <- err
-	}()
-	select {
-	case err := <-errc:
-		return err
-	case <-time.After(CommunicationTimeout):
-		return engine.ErrTimeout
-	}
-	panic("unreachable")
-}
+// searchTimeout is sent to comm to set its idleWait.
+type searchTimeout time.Duration
 
-func (c *comm) close(err error) {
+// close closes the engine process, setting the error state to err. It returns
+// the error from closing the process.
+func (c *comm) close(err error) error {
 	c.err = err
-	c.process.Close()
+	cerr := c.process.Close()
 	if c.infoc != nil {
 		c.infoc <- Info{err: err}
 		close(c.infoc)
 		c.infoc = nil
 	}
+	return cerr
 }
 
 func (c *comm) run() {
 	var timeout <-chan time.Time
+	var idle *time.Timer // search inactivity timer
+	var idlec <-chan time.Time
+	resetIdle := func() {
+		if idle != nil {
+			idle.Stop()
+		}
+		idle, idlec = nil, nil
+		if c.infoc != nil && c.idleWait > 0 {
+			idle = time.NewTimer(c.idleWait)
+			idlec = idle.C
+		}
+	}
 	initialised := false
+	quitting := false
 	c.options = make(map[string]engine.Option)
 
 loop:
@@ -265,24 +552,41 @@ loop:
 		if c.err == nil {
 			switch v := in.(type) {
 			case string:
+				if v == "quit" && c.infoc != nil {
+					// end the search, so that
+					// readers of infoc don't wait
+					// for the bestmove forever
+					c.infoc <- Info{err: engine.ErrInterrupted}
+					close(c.infoc)
+					c.infoc = nil
+				}
 				if c.log != nil {
 					c.log.Println(">", v)
 				}
-				c.err = timeoutWrite(c.stdin, v)
+				c.err = proc.WriteLine(c.stdin, v, c.wait)
 				switch {
 				case c.err != nil:
 					c.close(c.err)
 				case v == "uci" || v == "isready" || v == "quit":
-					timeout = time.After(CommunicationTimeout)
+					timeout = time.After(c.wait)
 					errc = nil
+					quitting = v == "quit"
 				}
 			case *chess.Board:
 				c.board = v
+			case time.Duration:
+				c.wait = v
+				if p, ok := c.process.(*proc.Process); ok {
+					p.SetWait(v)
+				}
+			case searchTimeout:
+				c.idleWait = time.Duration(v)
 			case chan engine.Info:
 				if c.board == nil {
 					c.err = errors.New("SetPosition not called before search")
 				} else {
 					c.infoc = v
+					resetIdle()
 				}
 			case chan map[string]engine.Option:
 				errc <- nil
@@ -299,11 +603,19 @@ loop:
 	case line, ok := <-c.linec:
 		if !ok {
 			c.linec = nil
+			reply := c.err
 			if c.err == nil {
-				c.close(c.readError)
+				if quitting {
+					// the reply to quit is the result of
+					// cleaning up the process
+					reply = c.close(engine.ErrExited)
+				} else {
+					c.close(proc.ExitError(c.process, c.readError))
+					reply = c.err
+				}
 			}
 			if timeout != nil {
-				c.errc <- c.err
+				c.errc <- reply
 				timeout = nil
 			}
 			break
@@ -311,18 +623,27 @@ loop:
 		if c.log != nil {
 			log.Println("|", line)
 		}
-		switch field := tokenise(line); field.next() {
+		switch field := proc.Tokenise(line); field.Next() {
 		case "id":
-			switch field.next() {
+			switch field.Next() {
 			case "name":
-				c.name = field.remainder()
+				c.name = field.Remainder()
 			case "author":
-				c.author = field.remainder()
+				c.author = field.Remainder()
 			}
 		case "option":
-			if !initialised {
-				c.parseOption(line)
+			if initialised {
+				// Some engines advertise options again, for
+				// instance after a setoption. The map may
+				// be in use by callers of Options, so it is
+				// replaced instead of changed.
+				options := make(map[string]engine.Option, len(c.options)+1)
+				for name, opt := range c.options {
+					options[name] = opt
+				}
+				c.options = options
 			}
+			c.parseOption(line)
 		case "uciok":
 			if !initialised && timeout != nil {
 				c.errc <- nil
@@ -337,23 +658,51 @@ loop:
 		case "info":
 			if c.infoc != nil {
 				c.infoc <- Info{line: line, board: c.board}
+				resetIdle()
 			}
 		case "bestmove":
 			if c.infoc != nil {
 				c.infoc <- Info{line: line, board: c.board}
 				close(c.infoc)
 				c.infoc = nil
+				resetIdle()
 			}
 		}
+	case infoc := <-c.stopc:
+		c.stopSearch(infoc)
 	case <-timeout:
 		c.close(engine.ErrTimeout)
 		c.errc <- c.err
 		timeout = nil
+	case <-idlec:
+		idle, idlec = nil, nil
+		// the search may have ended otherwise in the meantime
+		if c.infoc != nil && c.err == nil {
+			c.close(engine.ErrTimeout)
+		}
 	}
 
 	goto loop
 }
 
+// stopSearch stops the search sending Infos on infoc, if it is still running.
+// SearchContext and BestMove stop their searches this way rather than by
+// calling Stop, as they must keep receiving Infos while the stop is sent, and
+// the reply to Stop could otherwise be taken by another command. They send
+// infoc in the same select in which they receive Infos, so that neither side
+// blocks, and stop requests for searches that have ended are ignored.
+func (c *comm) stopSearch(infoc chan engine.Info) {
+	if c.err != nil || c.infoc == nil || c.infoc != infoc {
+		return
+	}
+	if c.log != nil {
+		c.log.Println(">", "stop")
+	}
+	if c.err = proc.WriteLine(c.stdin, "stop", c.wait); c.err != nil {
+		c.close(c.err)
+	}
+}
+
 func (c *comm) parseOption(line string) {
 	var err error
 
@@ -366,16 +715,17 @@ func (c *comm) parseOption(line string) {
 		return
 	}
 	def, haveDefault := fieldValue(line, "default", optionKeywords)
+	if typ == "string" {
+		// a string default is free text that may contain keywords,
+		// such as "max" in a path; it is the last field of the line
+		def, haveDefault = fieldRemainder(line, "default")
+	}
 
-	opt := option{name, c.cmdc, c.errc}
+	set := proc.NewSetter(c.cmdc, c.errc, setOption)
 
 	switch typ {
 	case "string":
-		c.options[name] = &StringOption{
-			option: opt,
-			def:    def,
-			value:  def,
-		}
+		c.options[name] = proc.NewStringOption(set, name, def)
 	case "check":
 		defbool := false
 		if haveDefault {
@@ -383,11 +733,7 @@ func (c *comm) parseOption(line string) {
 				defbool = false
 			}
 		}
-		c.options[name] = &BoolOption{
-			option: opt,
-			def:    defbool,
-			value:  defbool,
-		}
+		c.options[name] = proc.NewBoolOption(set, name, defbool)
 	case "spin":
 		minint, maxint := 0, 0
 		if min, ok := fieldValue(line, "min", optionKeywords); ok {
@@ -406,17 +752,11 @@ func (c *comm) parseOption(line string) {
 				defint = minint
 			}
 		}
-		c.options[name] = &IntOption{
-			option: opt,
-			def:    defint,
-			value:  defint,
-			min:    minint,
-			max:    maxint,
-		}
+		c.options[name] = proc.NewIntOption(set, name, defint, minint, maxint)
 	case "combo":
-		// TODO
+		c.options[name] = proc.NewComboOption(set, name, def, fieldValues(line, "var", optionKeywords))
 	case "button":
-		// TODO
+		c.options[name] = proc.NewButtonOption(set, name)
 	default:
 		return
 	}
@@ -443,15 +783,15 @@ func (i Info) BestMove() (chess.Move, bool) {
 	return chess.NullMove, false
 }
 
+// Pv implements engine.Info. It returns nil if the info has no score. An info
+// with a score but without moves, such as "info depth 5 score cp 20
+// lowerbound" sent when the search fails high, returns a Pv with an empty
+// Moves slice.
 func (i Info) Pv() *engine.Pv {
-	pv, ok := i.Value("pv")
-	if !ok {
-		return nil
-	}
-
 	// score
 	s, mate := i.Value("mate")
 	if !mate {
+		var ok bool
 		s, ok = i.Value("cp")
 		if !ok {
 			return nil
@@ -464,18 +804,23 @@ func (i Info) Pv() *engine.Pv {
 	_, upper := i.Value("upperbound")
 	_, lower := i.Value("lowerbound")
 
-	// principal variation
-	b := i.board
-	fields := strings.Fields(pv)
-	moves := make([]chess.Move, 0, len(fields))
-	for _, move := range fields {
-		m, err := b.ParseMove(move)
-		if err != nil {
-			break
+	// win/draw/loss statistics, like the score from the side to move's
+	// perspective
+	var wdl [3]int
+	if s, ok := i.Value("wdl"); ok {
+		if f := strings.Fields(s); len(f) == 3 {
+			for k := range wdl {
+				wdl[k], _ = strconv.Atoi(f[k])
+			}
+			if i.board.SideToMove == chess.Black {
+				wdl[0], wdl[2] = wdl[2], wdl[0]
+			}
 		}
-		moves = append(moves, m)
-		b = b.MakeMove(m)
 	}
+
+	// principal variation, if any
+	pv, _ := i.Value("pv")
+	moves := parseMoves(i.board, strings.Fields(pv))
 	multipv, ok := i.Value("multipv")
 	if !ok {
 		multipv = "0"
@@ -493,16 +838,88 @@ func (i Info) Pv() *engine.Pv {
 		Upperbound: upper,
 		Lowerbound: lower,
 		Rank:       rank,
+		Wdl:        wdl,
+		SideToMove: i.board.SideToMove,
+	}
+}
+
+// Refutation returns the moves following the "refutation" keyword: a move
+// followed by the line refuting it. It returns nil if the info has no
+// refutation.
+func (i Info) Refutation() []chess.Move {
+	v, ok := i.Value("refutation")
+	if !ok {
+		return nil
+	}
+	return parseMoves(i.board, strings.Fields(v))
+}
+
+// CurrLine returns the line the engine is currently searching on the given
+// cpu (1-based). The cpu number is optional in UCI; it is 1 if the engine
+// leaves it out. It returns nil moves if the info has no current line.
+func (i Info) CurrLine() (cpu int, moves []chess.Move) {
+	v, ok := i.Value("currline")
+	if !ok {
+		return 0, nil
+	}
+	fields := strings.Fields(v)
+	cpu = 1
+	if len(fields) > 0 {
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			cpu = n
+			fields = fields[1:]
+		}
+	}
+	return cpu, parseMoves(i.board, fields)
+}
+
+// parseMoves parses a sequence of moves in UCI notation starting from board b,
+// stopping at the first invalid move.
+func parseMoves(b *chess.Board, fields []string) []chess.Move {
+	moves := make([]chess.Move, 0, len(fields))
+	for _, move := range fields {
+		m, err := b.ParseMove(move)
+		if err != nil {
+			break
+		}
+		moves = append(moves, m)
+		b = b.MakeMove(m)
 	}
+	return moves
 }
 
 func (i Info) Stats() *engine.Stats {
-	return &engine.Stats{
-		Depth:    i.intval("depth"),
-		SelDepth: i.intval("seldepth"),
-		Nodes:    i.intval("nodes"),
-		Time:     time.Duration(i.intval("time")) * time.Millisecond,
+	stats := &engine.Stats{
+		Depth:          i.intval("depth"),
+		SelDepth:       i.intval("seldepth"),
+		Nodes:          i.intval("nodes"),
+		Time:           time.Duration(i.intval("time")) * time.Millisecond,
+		CurrMoveNumber: i.intval("currmovenumber"),
+		HashFull:       i.intval("hashfull"),
+		Nps:            i.int64val("nps"),
+	}
+	if move, ok := i.Value("currmove"); ok {
+		if m, err := i.board.ParseMove(move); err == nil {
+			stats.CurrMove = m
+		}
 	}
+	return stats
+}
+
+// Current returns the move the engine is currently searching, its 1-based
+// number in the engine's move list and the search depth, for showing search
+// progress. Number and depth are zero if not reported. It returns !ok if the
+// info has no valid currmove.
+func (i Info) Current() (move chess.Move, number, depth int, ok bool) {
+	v, ok := i.Value("currmove")
+	if !ok {
+		return chess.NullMove, 0, 0, false
+	}
+	move, err := i.board.ParseMove(v)
+	if err != nil {
+		return chess.NullMove, 0, 0, false
+	}
+	return move, i.intval("currmovenumber"), i.intval("depth"), true
 }
 
 // Value returns the value of the given keyword. It returns !ok if the keyword
@@ -511,6 +928,18 @@ func (i Info) Value(key string) (v string, ok bool) {
 	return fieldValue(i.line, key, infoKeywords)
 }
 
+// String returns the text following the "string" keyword, which engines use
+// to send free-form messages. It returns !ok if the info has no string.
+func (i Info) String() (s string, ok bool) {
+	return i.Value("string")
+}
+
+// Raw returns the full line as sent by the engine, allowing callers to parse
+// engine-specific extensions.
+func (i Info) Raw() string {
+	return i.line
+}
+
 func (i Info) intval(key string) int {
 	v, ok := i.Value(key)
 	if !ok {
@@ -523,88 +952,38 @@ func (i Info) intval(key string) int {
 	return x
 }
 
-// Options
-
-type option struct {
-	name string
-	cmdc chan<- interface{}
-	errc <-chan error
-}
-
-func (o *option) send(cmd string) {
-	o.cmdc <- cmd
-	<-o.errc
-}
-
-type StringOption struct {
-	option
-	def   string
-	value string
-}
-
-func (s *StringOption) StringDefault() string { return s.def }
-func (s *StringOption) String() string        { return s.value }
-func (s *StringOption) Set(value string) {
-	s.value = value
-	s.send(fmt.Sprintf("setoption name %s value %s", s.name, s.value))
-}
-
-type IntOption struct {
-	option
-	def   int
-	value int
-	min   int
-	max   int
-}
-
-func (i *IntOption) StringDefault() string { return fmt.Sprint(i.def) }
-func (i *IntOption) String() string        { return fmt.Sprint(i.value) }
-func (i *IntOption) Default() int          { return i.def }
-func (i *IntOption) Int() int              { return i.value }
-func (i *IntOption) Min() int              { return i.min }
-func (i *IntOption) Max() int              { return i.max }
-
-func (i *IntOption) Set(value string) {
-	v, err := strconv.Atoi(value)
+func (i Info) int64val(key string) int64 {
+	v, ok := i.Value(key)
+	if !ok {
+		return 0
+	}
+	x, err := strconv.ParseInt(v, 10, 64)
 	if err != nil {
-		panic(err)
+		return 0
 	}
-	i.SetInt(v)
-}
-
-func (i *IntOption) SetInt(v int) {
-	i.value = v
-	i.send(fmt.Sprintf("setoption name %s value %d", i.name, i.value))
+	return x
 }
 
-type BoolOption struct {
-	option
-	def   bool
-	value bool
-}
+// Options
 
-func (b *BoolOption) StringDefault() string { return fmt.Sprint(b.def) }
-func (b *BoolOption) String() string        { return fmt.Sprint(b.value) }
-func (b *BoolOption) Default() bool         { return b.def }
-func (b *BoolOption) Bool() bool            { return b.value }
+// The option types implement the option interfaces of package engine.
+type (
+	StringOption = proc.StringOption
+	IntOption    = proc.IntOption
+	BoolOption   = proc.BoolOption
+	ComboOption  = proc.ComboOption
+	ButtonOption = proc.ButtonOption
+)
 
-func (b *BoolOption) Set(value string) {
-	v, err := strconv.ParseBool(value)
-	if err != nil {
-		panic(err)
+// setOption returns the setoption command setting an option to value, or
+// pressing a button if value is nil.
+func setOption(name string, value interface{}) string {
+	if value == nil {
+		return "setoption name " + name
 	}
-	b.SetBool(v)
-}
-
-func (b *BoolOption) SetBool(v bool) {
-	b.value = v
-	b.send(fmt.Sprintf("setoption name %s value %v", b.name, b.value))
+	return fmt.Sprintf("setoption name %s value %v", name, value)
 }
 
-var _ engine.StringOption = &StringOption{}
-var _ engine.BoolOption = &BoolOption{}
-var _ engine.IntOption = &IntOption{}
-
 // fields
 
 var infoKeywords = map[string]bool{
@@ -631,6 +1010,7 @@ var infoKeywords = map[string]bool{
 	"tbhits":         true,
 	"time":           true,
 	"upperbound":     true,
+	"wdl":            true,
 }
 
 var optionKeywords = map[string]bool{
@@ -642,57 +1022,58 @@ var optionKeywords = map[string]bool{
 	"var":     true,
 }
 
-type fields struct {
-	line string
-	pos  int
-}
-
-func tokenise(line string) *fields {
-	return &fields{line, 0}
-}
-
-func (f *fields) next() string {
-	notIsSpace := func(r rune) bool { return !unicode.IsSpace(r) }
-
-	l := f.line[f.pos:]
-	i := strings.IndexFunc(l, notIsSpace)
-	if i < 0 {
-		return ""
-	}
-	j := i + strings.IndexFunc(l[i:], unicode.IsSpace)
-	if j < i {
-		j = len(l)
-	}
-	f.pos += j
-	return l[i:j]
-}
-
-func (f *fields) hasNext() bool {
-	return f.pos < len(f.line)
-}
-
-func (f *fields) remainder() string {
-	return strings.TrimSpace(f.line[f.pos:])
-}
-
 func fieldValue(line, key string, keyword map[string]bool) (v string, ok bool) {
-	field := &fields{line, 0}
-	for field.next() != key {
-		if !field.hasNext() {
+	field := proc.Tokenise(line)
+	for field.Next() != key {
+		if !field.HasNext() {
 			return "", false
 		}
 	}
 	if key == "string" {
 		// after the "string" keyword ignore other keywords
-		return field.remainder(), true
+		return field.Remainder(), true
 	}
-	p, q := field.pos, field.pos
-	for field.hasNext() {
-		f := field.next()
+	p, q := field.Pos, field.Pos
+	for field.HasNext() {
+		f := field.Next()
 		if keyword[f] {
 			break
 		}
-		q = field.pos
+		q = field.Pos
 	}
 	return strings.TrimSpace(line[p:q]), true
 }
+
+// fieldRemainder returns the rest of line following key, keywords and all.
+func fieldRemainder(line, key string) (v string, ok bool) {
+	field := proc.Tokenise(line)
+	for field.Next() != key {
+		if !field.HasNext() {
+			return "", false
+		}
+	}
+	return field.Remainder(), true
+}
+
+// fieldValues is like fieldValue, but returns the values of all occurrences of
+// key in line.
+func fieldValues(line, key string, keyword map[string]bool) []string {
+	var values []string
+	field := proc.Tokenise(line)
+	for field.HasNext() {
+		if field.Next() != key {
+			continue
+		}
+		p, q := field.Pos, field.Pos
+		for field.HasNext() {
+			pos := field.Pos
+			if keyword[field.Next()] {
+				field.Pos = pos // unread the keyword
+				break
+			}
+			q = field.Pos
+		}
+		values = append(values, strings.TrimSpace(line[p:q]))
+	}
+	return values
+}
@@ -1,13 +1,20 @@
 package uci
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/malbrecht/chess"
 	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/engine/internal/enginetest"
+	"github.com/malbrecht/chess/engine/internal/proc"
 	"io"
 	"log"
 	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"text/tabwriter"
 	"time"
@@ -56,21 +63,23 @@ func TestStockfish(t *testing.T) {
 	}
 }
 
-type optionTest struct {
-	name  string
-	typ   string
-	other string
-	set   string
-	value interface{}
-}
-
-var optionTests = []optionTest{
-	{"number option 1", "spin", "default 5 min 1 max 10", "", 5},
-	{"number option 2", "spin", "default 5 min 1 max 10", "7", 7},
-	{"string option 1", "string", "default Ab Cd", "", "Ab Cd"},
-	{"string option 2", "string", "default Ab Cd", "xyz", "xyz"},
-	{"bool option 1", "check", "", "", false},
-	{"bool option 2", "check", "", "true", true},
+var optionTests = []enginetest.OptionTest{
+	{Decl: "name number option 1 type spin default 5 min 1 max 10", Name: "number option 1", Value: 5},
+	{Decl: "name number option 2 type spin default 5 min 1 max 10", Name: "number option 2", Set: "7", Value: 7,
+		Command: "setoption name number option 2 value 7"},
+	{Decl: "name string option 1 type string default Ab Cd", Name: "string option 1", Value: "Ab Cd"},
+	{Decl: "name string option 2 type string default Ab Cd", Name: "string option 2", Set: "xyz", Value: "xyz",
+		Command: "setoption name string option 2 value xyz"},
+	{Decl: `name SyzygyPath type string default C:\Tables max 2 var x`, Name: "SyzygyPath", Value: `C:\Tables max 2 var x`},
+	{Decl: "name bool option 1 type check", Name: "bool option 1", Value: false},
+	{Decl: "name bool option 2 type check", Name: "bool option 2", Set: "true", Value: true,
+		Command: "setoption name bool option 2 value true"},
+	{Decl: "name combo option 1 type combo default Normal var Solid var Normal var Very Risky", Name: "combo option 1", Value: "Normal"},
+	{Decl: "name combo option 2 type combo default Normal var Solid var Normal var Very Risky", Name: "combo option 2",
+		Set: "very risky", Value: "Very Risky", Command: "setoption name combo option 2 value Very Risky"},
+	{Decl: "name Clear Hash type button", Name: "Clear Hash"},
+	{Decl: "name UCI_Chess960 type check default false", Name: "UCI_Chess960", Value: false},
+	{Decl: "name MultiPV type spin default 1 min 1 max 500", Name: "MultiPV", Value: 1},
 }
 
 type infoTest struct {
@@ -81,49 +90,138 @@ type infoTest struct {
 }
 
 var infoTests = []infoTest{
-	{"info nodes 1000 time 6789", nil, 0, &engine.Stats{0, 0, 1000, 6789 * time.Millisecond}},
+	{"info nodes 1000 time 6789", nil, 0, &engine.Stats{Nodes: 1000, Time: 6789 * time.Millisecond}},
+	{"info currmove e7e5 currmovenumber 3 depth 20", nil, 0, &engine.Stats{
+		Depth: 20, CurrMove: chess.Move{From: chess.E7, To: chess.E5}, CurrMoveNumber: 3}},
+	{"info depth 20 nodes 1000000 nps 2000000 hashfull 321", nil, 0, &engine.Stats{
+		Depth: 20, Nodes: 1000000, Nps: 2000000, HashFull: 321}},
+	{"info refutation e7e5 g1f3", nil, 0, &engine.Stats{}},
+	{"info currline 2 e7e5 g1f3 b8c6", nil, 0, &engine.Stats{}},
 	{"info pv e7e5 g1f3 b8c3 f1b5 score cp 29", nil, -29, nil},
+	{"info depth 21 score cp 35 lowerbound", nil, -35, nil},
 	{"bestmove e7e5 ponder g1f3", &chess.Move{chess.E7, chess.E5, 0}, 0, nil},
 }
 
-func fakeEngine(r io.Reader, w io.WriteCloser) {
-	buf := bufio.NewReader(r)
-	for {
-		line, _, err := buf.ReadLine()
-		if err != nil {
-			return
-		}
-		switch field := tokenise(string(line)); field.next() {
+// multiPVLines are sent by the fake engine instead of infoTests when its
+// MultiPV option is set above 1. Moves are from the starting position.
+var multiPVLines = []string{
+	"info depth 1 multipv 1 score cp 30 pv e2e4",
+	"info depth 1 multipv 2 score cp 20 pv d2d4",
+	"info depth 1 multipv 3 score cp 10 pv g1f3",
+	"info depth 2 multipv 1 score cp 25 pv d2d4 d7d5",
+	"info depth 3 multipv 1 score cp 40 lowerbound",
+	"info depth 2 multipv 2 score cp 15 pv e2e4 e7e5",
+	"info depth 2 multipv 3 score cp 5 pv c2c4 e7e5",
+	"info depth 2 nodes 1000",
+	"bestmove d2d4",
+}
+
+// fakeEngine returns the command handler of a fake engine, see
+// enginetest.Start.
+func fakeEngine() func(cmd string, out chan<- string) bool {
+	hung := false // ignore isready, like an engine that is stuck
+	multiPV := 1  // value of the MultiPV option
+	return func(line string, out chan<- string) bool {
+		switch field := proc.Tokenise(line); field.Next() {
 		case "uci":
 			for _, o := range optionTests {
-				fmt.Fprintf(w, "option name %s type %s %s\n", o.name, o.typ, o.other)
+				out <- "option " + o.Decl
 			}
-			fmt.Fprintln(w, "uciok")
+			out <- "uciok"
 		case "isready":
-			fmt.Fprintln(w, "readyok")
+			if !hung {
+				out <- "readyok"
+			}
+		case "hang":
+			hung = true
+		case "readvertise":
+			// advertise an option again, and a new one
+			out <- "option name string option 1 type string default changed"
+			out <- "option name Threads type spin default 1 min 1 max 64"
 		case "setoption":
-			// ignore
+			// only MultiPV changes the output
+			if f := strings.Fields(line); len(f) == 5 && f[2] == "MultiPV" {
+				multiPV, _ = strconv.Atoi(f[4])
+			}
 		case "go":
+			// an infinite or ponder search only sends the bestmove
+			// after being stopped
+			infinite := strings.Contains(line, "infinite") ||
+				strings.Contains(line, "ponder")
+			if strings.Contains(line, "silent") {
+				// stall in the middle of the search
+				out <- infoTests[0].line
+				break
+			}
+			if strings.Contains(line, "crash") {
+				// exit in the middle of the search
+				out <- infoTests[0].line
+				return false
+			}
+			if multiPV > 1 {
+				for _, l := range multiPVLines {
+					out <- l
+				}
+				break
+			}
+			for _, i := range infoTests {
+				if i.bestmove == nil || !infinite {
+					out <- i.line
+				}
+			}
+		case "stop", "ponderhit":
 			for _, i := range infoTests {
-				fmt.Fprintln(w, i.line)
+				if i.bestmove != nil {
+					out <- i.line
+				}
 			}
 		case "quit":
-			w.Close()
-			return
+			return false
 		}
+		return true
 	}
 }
 
-func TestEngine(t *testing.T) {
+// startFakeEngine starts a fake engine and returns an Engine communicating
+// with it, and the recorder of the commands the fake engine receives.
+func startFakeEngine(t *testing.T) (*Engine, *enginetest.Recorder) {
+	return startFakeEngineCloser(t, nil)
+}
+
+// startFakeEngineCloser is like startFakeEngine, but the process is closed by
+// calling wait, if not nil, after closing the engine's input.
+func startFakeEngineCloser(t *testing.T, wait func() error) (*Engine, *enginetest.Recorder) {
 	var logger *log.Logger //= log.New(stdout, "", log.LstdFlags)
 
-	r0, w0 := io.Pipe()
-	r1, w1 := io.Pipe()
-	go fakeEngine(r1, w0)
-	e, err := initialise(r0, w1, w1, logger)
+	stdout, stdin, rec := enginetest.Start(fakeEngine())
+	var closer io.Closer = stdin
+	if wait != nil {
+		closer = closerFunc(func() error {
+			stdin.Close()
+			return wait()
+		})
+	}
+	e, err := initialise(stdout, stdin, closer, logger, CommunicationTimeout)
 	if err != nil {
-		log.Fatal("engine initialisation failed:", err)
+		t.Fatal("engine initialisation failed:", err)
+	}
+	return e, rec
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// drain reads infoc until it is closed and returns the last Info.
+func drain(infoc <-chan engine.Info) (last engine.Info) {
+	for info := range infoc {
+		last = info
 	}
+	return last
+}
+
+func TestEngine(t *testing.T) {
+	e, rec := startFakeEngine(t)
 	defer e.Quit()
 
 	// test options
@@ -131,32 +229,30 @@ func TestEngine(t *testing.T) {
 	if opts == nil {
 		t.Fatal("no options returned")
 	}
-	for _, o := range optionTests {
-		opt := opts[o.name]
-		if opt == nil {
-			t.Errorf("option %q not found", o.name)
-			continue
-		}
-		if o.set != "" {
-			opt.Set(o.set)
-		}
-		switch want := o.value.(type) {
-		case string:
-			s := opt.(*StringOption)
-			if got := s.String(); got != want {
-				t.Errorf("option %q: want %q, got %q", o.name, want, got)
-			}
-		case int:
-			i := opt.(*IntOption)
-			if got := i.Int(); got != want {
-				t.Errorf("option %q: want %d, got %d", o.name, want, got)
-			}
-		case bool:
-			b := opt.(*BoolOption)
-			if got := b.Bool(); got != want {
-				t.Errorf("option %q: want %v, got %v", o.name, want, got)
+	enginetest.CheckOptions(t, opts, optionTests, rec, e.Ping)
+
+	// test combo option choices
+	combo := opts["combo option 1"].(engine.ComboOption)
+	if got, want := combo.Choices(), []string{"Solid", "Normal", "Very Risky"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("combo option: got choices %q, want %q", got, want)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("combo option: invalid choice accepted")
 			}
-		}
+		}()
+		combo.Set("Reckless")
+	}()
+	if got := combo.Get(); got != "Normal" {
+		t.Errorf("combo option: got %q after invalid choice, want %q", got, "Normal")
+	}
+
+	// test button option
+	opts["Clear Hash"].(engine.ButtonOption).Press()
+	e.Ping() // make sure that the fake engine has processed the command
+	if got := rec.Commands("setoption name Clear Hash"); len(got) != 1 || got[0] != "setoption name Clear Hash" {
+		t.Errorf("button option: got commands %q", got)
 	}
 
 	// test search
@@ -195,3 +291,603 @@ func TestEngine(t *testing.T) {
 		t.Error("spurious info:", info.(Info))
 	}
 }
+
+func TestSearchNodes(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	e.SetPosition(chess.MustParseFen(""))
+	if info := drain(e.SearchNodes(5000)); info == nil || info.Err() != nil {
+		t.Fatal("search failed:", info)
+	}
+	if got := rec.Commands("go nodes"); len(got) == 0 || got[len(got)-1] != "go nodes 5000" {
+		t.Errorf("got commands %q, want go nodes 5000", got)
+	}
+}
+
+func TestPonder(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	e.SetPosition(chess.MustParseFen(""))
+	infoc := e.Ponder(chess.Move{From: chess.E2, To: chess.E4})
+	if info := <-infoc; info == nil || info.Err() != nil {
+		t.Fatal("ponder failed:", info)
+	}
+	go e.PonderHit() // the info channel must be read meanwhile
+	if info := drain(infoc); info == nil {
+		t.Fatal("no info after ponderhit")
+	} else if _, ok := info.BestMove(); !ok {
+		t.Errorf("last info is not a bestmove: %v", info)
+	}
+
+	var got []string
+	for _, line := range rec.Commands("") {
+		if strings.HasPrefix(line, "position") || strings.HasPrefix(line, "go") || line == "ponderhit" {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"position fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"position fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 moves e2e4",
+		"go ponder",
+		"ponderhit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+
+	// pondering on castling
+	e.SetPosition(chess.MustParseFen("4k3/8/8/8/8/8/8/4K2R w K - 0 1"))
+	infoc = e.Ponder(chess.Move{From: chess.E1, To: chess.H1})
+	stopped := make(chan bool)
+	go func() { e.Stop(); close(stopped) }() // the info channel must be read meanwhile
+	drain(infoc)
+	<-stopped
+	if got := rec.Commands("position"); !strings.HasSuffix(got[len(got)-1], " moves e1g1") {
+		t.Errorf("got commands %q, want ponder move sent as e1g1", got)
+	}
+}
+
+func TestEngineExited(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+
+	e.SetPosition(chess.MustParseFen(""))
+	info := drain(e.search("go crash"))
+	if info == nil || !errors.Is(info.Err(), engine.ErrEngineExited) {
+		t.Fatalf("got last info %v, want error %v", info, engine.ErrEngineExited)
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+
+	e.SetSearchTimeout(CommunicationTimeout / 10)
+	e.SetPosition(chess.MustParseFen(""))
+	// a search with regular output is not affected
+	if info := drain(e.SearchDepth(10)); info == nil || info.Err() != nil {
+		t.Fatalf("got last info %v", info)
+	}
+	done := make(chan engine.Info)
+	go func() { done <- drain(e.search("go silent")) }()
+	select {
+	case info := <-done:
+		if info == nil || info.Err() != engine.ErrTimeout {
+			t.Errorf("got last info %v, want error %v", info, engine.ErrTimeout)
+		}
+	case <-time.After(CommunicationTimeout):
+		t.Fatal("search of a silent engine did not time out")
+	}
+}
+
+func TestQuit(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	if err := e.Quit(); err != nil {
+		t.Errorf("clean exit: got error %v", err)
+	}
+
+	killed := errors.New("signal: killed")
+	e, _ = startFakeEngineCloser(t, func() error { return killed })
+	if err := e.Quit(); err != killed {
+		t.Errorf("got error %v, want %v", err, killed)
+	}
+}
+
+func TestQuitDuringSearch(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	e.SetPosition(chess.MustParseFen(""))
+	infoc := e.Search()
+	first := <-infoc // the search is running
+	if first == nil || first.Err() != nil {
+		t.Fatalf("got first info %v", first)
+	}
+	done := make(chan engine.Info)
+	go func() {
+		done <- drain(infoc)
+	}()
+	e.Quit()
+	select {
+	case last := <-done:
+		if last == nil || last.Err() != engine.ErrInterrupted {
+			t.Errorf("got last info %v, want error %v", last, engine.ErrInterrupted)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("info channel not closed after Quit")
+	}
+}
+
+func TestOptionsReadvertised(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+	before := e.Options()
+	e.Send("readvertise")
+	if err := e.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	opts := e.Options()
+	if opt := opts["string option 1"]; opt == nil || opt.String() != "changed" {
+		t.Errorf("readvertised option: got %v", opt)
+	}
+	if opt, ok := opts["Threads"].(*IntOption); !ok || opt.Int() != 1 {
+		t.Errorf("new option: got %v", opts["Threads"])
+	}
+	if len(opts) != len(optionTests)+1 {
+		t.Errorf("got %d options, want %d", len(opts), len(optionTests)+1)
+	}
+	// maps returned earlier are not changed
+	if _, ok := before["Threads"]; ok || before["string option 1"].String() != "Ab Cd" {
+		t.Error("options map returned before was changed")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+	want := Capabilities{MultiPV: true, Chess960: true}
+	if got := e.Capabilities(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	e1, _ := startFakeEngine(t)
+	defer e1.Quit()
+	e2, _ := startFakeEngine(t)
+	defer e2.Quit()
+
+	timeout := CommunicationTimeout / 10
+	e1.SetTimeout(timeout)
+	e1.Send("hang")
+	start := time.Now()
+	if err := e1.Ping(); err != engine.ErrTimeout {
+		t.Errorf("got error %v, want %v", err, engine.ErrTimeout)
+	}
+	if d := time.Since(start); d >= CommunicationTimeout {
+		t.Errorf("timeout after %v, want %v", d, timeout)
+	}
+
+	// the other engine is not affected
+	if err := e2.Ping(); err != nil {
+		t.Errorf("other engine: got error %v", err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep command to act as a silent engine")
+	}
+	// the engine neither answers "uci" nor exits: both the start-up and
+	// the wait before killing it use the engine's timeout
+	timeout := CommunicationTimeout / 10
+	start := time.Now()
+	if _, err := RunTimeout(sleep, []string{"60"}, nil, timeout); err != engine.ErrTimeout {
+		t.Errorf("got error %v, want %v", err, engine.ErrTimeout)
+	}
+	if d := time.Since(start); d >= CommunicationTimeout {
+		t.Errorf("gave up after %v, want %v", d, 2*timeout)
+	}
+}
+
+func TestSearchMoves(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	e.SetPosition(chess.MustParseFen(""))
+	moves := []chess.Move{{From: chess.E2, To: chess.E4}, {From: chess.G1, To: chess.F3}}
+	if info := drain(e.SearchMoves(moves, SearchLimit{Depth: 10})); info == nil || info.Err() != nil {
+		t.Fatal("search failed:", info)
+	}
+	e.SetPosition(chess.MustParseFen("4k3/8/8/8/8/8/8/4K2R w K - 0 1"))
+	castling := []chess.Move{{From: chess.E1, To: chess.H1}, {From: chess.E1, To: chess.D1}}
+	if info := drain(e.SearchMoves(castling, SearchLimit{Depth: 10})); info == nil || info.Err() != nil {
+		t.Fatal("search failed:", info)
+	}
+	want := []string{"go depth 10 searchmoves e2e4 g1f3", "go depth 10 searchmoves e1g1 e1d1"}
+	if got := rec.Commands("go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
+
+func TestSearchMovesWithoutPosition(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+
+	moves := []chess.Move{{From: chess.E2, To: chess.E4}}
+	if info := drain(e.SearchMoves(moves, SearchLimit{Depth: 10})); info == nil || info.Err() == nil {
+		t.Errorf("got last info %v, want an error", info)
+	}
+}
+
+func TestBestMove(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	board := chess.MustParseFen("").MakeMove(chess.Move{From: chess.E2, To: chess.E4})
+	move, pv, err := e.BestMove(context.Background(), board, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if san := move.San(board); san != "e5" {
+		t.Errorf("got bestmove %s, want e5", san)
+	}
+	// the bound without moves does not replace the last line
+	if pv == nil || pv.Score != -29 || len(pv.Moves) == 0 {
+		t.Errorf("got pv %v, want score -29 with moves", pv)
+	}
+	if got := rec.Commands("go"); len(got) != 1 || got[0] != "go movetime 100" {
+		t.Errorf("got commands %q, want go movetime 100", got)
+	}
+}
+
+func TestBestMoveCancelled(t *testing.T) {
+	e, _ := startFakeEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	board := chess.MustParseFen("")
+	if _, _, err := e.BestMove(ctx, board, time.Hour); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	// a pending stop must not be mistaken for a later command
+	if err := e.Ping(); err != nil {
+		t.Errorf("Ping after BestMove: %v", err)
+	}
+	if err := e.Quit(); err != nil {
+		t.Errorf("Quit after BestMove: %v", err)
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	e, rec := startFakeEngine(t)
+
+	e.SetPosition(chess.MustParseFen(""))
+	ctx, cancel := context.WithCancel(context.Background())
+	infoc := e.SearchContext(ctx)
+	if info := <-infoc; info == nil || info.Err() != nil {
+		t.Fatal("search failed:", info)
+	}
+	cancel()
+	lastc := make(chan engine.Info)
+	go func() { lastc <- drain(infoc) }()
+	select {
+	case info := <-lastc:
+		if _, ok := info.BestMove(); !ok {
+			t.Errorf("last info is not a bestmove: %v", info)
+		}
+	case <-time.After(CommunicationTimeout):
+		t.Fatal("search was not stopped")
+	}
+	// the stop must not be mistaken for a later command
+	if err := e.Ping(); err != nil {
+		t.Errorf("Ping after search: %v", err)
+	}
+	if got := rec.Commands("stop"); len(got) != 1 {
+		t.Errorf("got %d stop commands, want 1", len(got))
+	}
+	if err := e.Quit(); err != nil {
+		t.Errorf("Quit after search: %v", err)
+	}
+}
+
+func TestCollectMultiPV(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+
+	board := chess.MustParseFen("")
+	e.SetPosition(board)
+	e.Options()["MultiPV"].Set("3")
+	pvs, err := engine.CollectMultiPV(e.SearchDepth(2), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		move  string
+		score int
+	}{{"d4", 25}, {"e4", 15}}
+	if len(pvs) != len(want) {
+		t.Fatalf("got %d pvs, want %d", len(pvs), len(want))
+	}
+	for i, w := range want {
+		if pvs[i] == nil {
+			t.Errorf("rank %d: no pv", i)
+			continue
+		}
+		if move := pvs[i].Moves[0].San(board); move != w.move || pvs[i].Score != w.score {
+			t.Errorf("rank %d: got %s %d, want %s %d", i, move, pvs[i].Score, w.move, w.score)
+		}
+	}
+
+	if _, err := engine.CollectMultiPV(nil, 0); err == nil {
+		t.Error("n = 0: got no error")
+	}
+}
+
+func TestNewGame(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	board := chess.MustParseFen("")
+	e.SetPosition(board)
+	e.SetPosition(board.MakeMove(chess.Move{From: chess.E2, To: chess.E4}))
+	e.Ping()
+	if got := rec.Commands("ucinewgame"); len(got) != 0 {
+		t.Errorf("SetPosition sent %q", got)
+	}
+	if got := rec.Commands("position"); len(got) != 2 {
+		t.Errorf("got position commands %q, want 2", got)
+	}
+	e.NewGame()
+	e.SetAutoNewGame(true)
+	e.SetPosition(board)
+	e.Ping()
+	if got := rec.Commands("ucinewgame"); len(got) != 2 {
+		t.Errorf("got %d ucinewgame commands, want 2", len(got))
+	}
+}
+
+func TestSetPositionMoves(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	start := chess.MustParseFen("")
+	moves := []chess.Move{{From: chess.E2, To: chess.E4}, {From: chess.E7, To: chess.E5}}
+	e.SetPositionMoves(start, moves)
+	e.SetPositionMoves(start, nil)
+	other := chess.MustParseFen("4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	e.SetPositionMoves(other, []chess.Move{{From: chess.E1, To: chess.H1}})
+	e.Ping()
+	want := []string{
+		"position startpos moves e2e4 e7e5",
+		"position startpos",
+		"position fen 4k3/8/8/8/8/8/8/4K2R w K - 0 1 moves e1g1",
+	}
+	if got := rec.Commands("position"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+	// moves from the engine are relative to the position after the moves
+	if e.board.Piece[chess.G1] != chess.WK {
+		t.Errorf("engine board after O-O: %s", e.board.Fen())
+	}
+}
+
+func TestChess960(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	board := chess.MustParseFen("r2k3r/pppppppp/8/8/8/8/PPPPPPPP/R2K3R w KQkq - 0 1")
+	e.SetChess960(true)
+	e.SetPosition(board)
+	e.Ping()
+	if got := rec.Commands("setoption name UCI_Chess960"); len(got) != 1 || got[0] != "setoption name UCI_Chess960 value true" {
+		t.Errorf("got commands %q, want UCI_Chess960 set to true", got)
+	}
+	want := "position fen r2k3r/pppppppp/8/8/8/8/PPPPPPPP/R2K3R w HAha - 0 1"
+	if got := rec.Commands("position"); len(got) != 1 || got[0] != want {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+
+	// castling is sent and received as king-takes-own-rook
+	move, ok := Info{line: "bestmove d1h1", board: board}.BestMove()
+	if !ok {
+		t.Fatal("bestmove d1h1 not parsed")
+	}
+	if san := move.San(board); san != "O-O" {
+		t.Errorf("bestmove d1h1: got %s, want O-O", san)
+	}
+	if uci := move.Uci(board); uci != "d1h1" {
+		t.Errorf("O-O: got %s, want d1h1", uci)
+	}
+	e.SetPositionMoves(board, []chess.Move{move})
+	e.Ping()
+	if got := rec.Commands("position"); len(got) != 2 || !strings.HasSuffix(got[1], " moves d1h1") {
+		t.Errorf("got commands %q, want O-O sent as d1h1", got)
+	}
+}
+
+func TestSaveRestoreOptions(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	saved := e.SaveOptions()
+	if _, ok := saved["Clear Hash"]; ok {
+		t.Error("button option saved")
+	}
+	options := e.Options()
+	num := options["number option 1"].(*IntOption)
+	check := options["bool option 1"].(*BoolOption)
+	num.SetInt(8)
+	check.SetBool(true)
+
+	e.RestoreOptions(saved)
+	if num.Int() != num.Default() || check.Bool() != check.Default() {
+		t.Errorf("got values %d and %v after restoring, want defaults %d and %v",
+			num.Int(), check.Bool(), num.Default(), check.Default())
+	}
+	e.Ping()
+	want := []string{
+		"setoption name number option 1 value 8",
+		"setoption name number option 1 value 5",
+	}
+	if got := rec.Commands("setoption name number option 1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	board := chess.MustParseFen("")
+	line := "info depth 12 string low on time"
+	info := Info{line: line, board: board}
+	if s, ok := info.String(); !ok || s != "low on time" {
+		t.Errorf("got string %q, %v, want %q", s, ok, "low on time")
+	}
+	if raw := info.Raw(); raw != line {
+		t.Errorf("got raw line %q, want %q", raw, line)
+	}
+	if s, ok := (Info{line: "info depth 12", board: board}).String(); ok {
+		t.Errorf("got string %q for info without string", s)
+	}
+}
+
+func TestWdl(t *testing.T) {
+	line := "info depth 20 score cp 34 wdl 600 350 50 pv e7e5"
+	white := chess.MustParseFen("")
+	black := white.MakeMove(chess.Move{From: chess.E2, To: chess.E4})
+	for _, tt := range []struct {
+		board *chess.Board
+		score int
+		wdl   [3]int
+	}{
+		{white, 34, [3]int{600, 350, 50}},
+		{black, -34, [3]int{50, 350, 600}},
+	} {
+		pv := Info{line: line, board: tt.board}.Pv()
+		if pv == nil {
+			t.Fatal("no pv in", line)
+		}
+		if pv.Score != tt.score || pv.Wdl != tt.wdl {
+			t.Errorf("got score %d wdl %v, want %d %v", pv.Score, pv.Wdl, tt.score, tt.wdl)
+		}
+	}
+	if pv := (Info{line: "info score cp 34 pv e2e4", board: white}).Pv(); pv.Wdl != [3]int{} {
+		t.Errorf("got wdl %v for info without wdl", pv.Wdl)
+	}
+}
+
+func TestPvBound(t *testing.T) {
+	board := chess.MustParseFen("")
+	pv := Info{line: "info depth 5 score cp 20 lowerbound", board: board}.Pv()
+	if pv == nil {
+		t.Fatal("no pv for score without moves")
+	}
+	if pv.Moves == nil || len(pv.Moves) != 0 {
+		t.Errorf("got moves %v, want an empty slice", pv.Moves)
+	}
+	if pv.Score != 20 || !pv.Lowerbound || pv.Upperbound {
+		t.Errorf("got score %d lowerbound %v upperbound %v, want 20 true false",
+			pv.Score, pv.Lowerbound, pv.Upperbound)
+	}
+	if pv := (Info{line: "info depth 5 pv e2e4", board: board}).Pv(); pv != nil {
+		t.Errorf("got pv %v for info without score", pv)
+	}
+}
+
+func TestWhiteScore(t *testing.T) {
+	black := chess.MustParseFen("").MakeMove(chess.Move{From: chess.E2, To: chess.E4})
+	// fool's mate: White to move is mated
+	mated := chess.MustParseFen("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	// back rank mate: Black to move is mated
+	blackMated := chess.MustParseFen("3R2k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	for _, tt := range []struct {
+		board *chess.Board
+		line  string
+		score int
+		mate  int
+		ok    bool
+	}{
+		// scores are sent from the point of view of the side to move
+		{black, "info score cp 50 pv e7e5", -50, 0, false},
+		{black, "info score cp -120 pv e7e5", 120, 0, false},
+		{black, "info score mate 3 pv e7e5", -engine.MateScore + 3, -3, true},
+		{black, "info score mate -2 pv e7e5", engine.MateScore - 2, 2, true},
+		// mate 0: the side to move is mated
+		{mated, "info depth 0 score mate 0", -engine.MateScore, 0, true},
+		{blackMated, "info depth 0 score mate 0", engine.MateScore, 0, true},
+	} {
+		pv := Info{line: tt.line, board: tt.board}.Pv()
+		if pv == nil {
+			t.Fatal("no pv in", tt.line)
+		}
+		mate, ok := pv.MateIn()
+		if score := pv.WhiteScore(); score != tt.score || mate != tt.mate || ok != tt.ok {
+			t.Errorf("%s: got WhiteScore %d MateIn %d %v, want %d %d %v",
+				tt.line, score, mate, ok, tt.score, tt.mate, tt.ok)
+		}
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	board := chess.MustParseFen("").MakeMove(chess.Move{From: chess.E2, To: chess.E4})
+	info := Info{line: "info depth 12 currmove g8f6 currmovenumber 4", board: board}
+	move, number, depth, ok := info.Current()
+	if !ok || move != (chess.Move{From: chess.G8, To: chess.F6}) || number != 4 || depth != 12 {
+		t.Errorf("got %v %d %d %v, want g8f6 4 12 true", move, number, depth, ok)
+	}
+	for _, line := range []string{"info depth 12 nodes 1000", "info currmove e2e4 depth 3"} {
+		if _, _, _, ok := (Info{line: line, board: board}).Current(); ok {
+			t.Errorf("%s: got ok", line)
+		}
+	}
+}
+
+func TestRefutationCurrLine(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	defer e.Quit()
+
+	board := chess.MustParseFen("").MakeMove(chess.Move{From: chess.E2, To: chess.E4})
+	e.SetPosition(board)
+	san := func(moves []chess.Move) string {
+		var s []string
+		b := board
+		for _, m := range moves {
+			s = append(s, m.San(b))
+			b = b.MakeMove(m)
+		}
+		return strings.Join(s, " ")
+	}
+	var refutation, currline bool
+	for info := range e.SearchDepth(1) {
+		if info.Err() != nil {
+			t.Fatal("search failed:", info.Err())
+		}
+		i := info.(Info)
+		switch {
+		case strings.HasPrefix(i.Raw(), "info refutation"):
+			refutation = true
+			if got, want := san(i.Refutation()), "e5 Nf3"; got != want {
+				t.Errorf("got refutation %q, want %q", got, want)
+			}
+		case strings.HasPrefix(i.Raw(), "info currline"):
+			currline = true
+			cpu, moves := i.CurrLine()
+			if got, want := san(moves), "e5 Nf3 Nc6"; cpu != 2 || got != want {
+				t.Errorf("got currline %d %q, want 2 %q", cpu, got, want)
+			}
+		default:
+			if i.Refutation() != nil {
+				t.Errorf("got refutation for %q", i.Raw())
+			}
+			if _, moves := i.CurrLine(); moves != nil {
+				t.Errorf("got currline for %q", i.Raw())
+			}
+		}
+	}
+	if !refutation || !currline {
+		t.Errorf("refutation (%v) or currline (%v) info missing", refutation, currline)
+	}
+	if cpu, moves := (Info{line: "info currline e7e5", board: board}).CurrLine(); cpu != 1 || len(moves) != 1 {
+		t.Errorf("currline without cpu: got %d %v, want 1 [e5]", cpu, moves)
+	}
+}
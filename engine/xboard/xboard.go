@@ -0,0 +1,693 @@
+// Package xboard (partly) implements the XBoard protocol, also known as the
+// Chess Engine Communication Protocol (CECP), version 2 for communicating
+// with chess engines.
+// (https://www.gnu.org/software/xboard/engine-intf.html)
+//
+// The protocol is built around playing games, so the engine.Engine searches
+// are mapped onto it as follows. Search, SearchDepth, SearchTime and
+// SearchNodes put the engine into analyze mode, and leave it again when the
+// limit is reached or Stop is called; the best move of such a search is the
+// first move of the last principal variation reported. SearchClock lets the
+// engine think on its move with "go" and reports the move it plays. Engines
+// must support the setboard feature.
+package xboard
+
+import (
+	"errors"
+	"fmt"
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/engine/internal/proc"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CommunicationTimeout is the time to wait for a response from the engine. If
+// the engine fails to respond, it is terminated. During start-up it is also
+// the time to wait for the engine to announce its features; engines that do
+// not (protocol version 1) are used with default features.
+var CommunicationTimeout time.Duration = 3 * time.Second
+
+// Engine represents a running XBoard engine.
+type Engine struct {
+	cmdc  chan<- interface{}
+	errc  <-chan error
+	board *chess.Board // position set by SetPosition
+}
+
+var _ engine.Engine = &Engine{}
+
+// Run starts an engine executable, with the given arguments. If logger is not
+// nil, it will be used to log all communication to and from the engine.
+func Run(exe string, args []string, logger *log.Logger) (*Engine, error) {
+	p, stdout, stdin, err := proc.Start(exe, args, CommunicationTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return initialise(stdout, stdin, p, logger)
+}
+
+func initialise(stdout io.Reader, stdin io.Writer, process io.Closer, logger *log.Logger) (*Engine, error) {
+	var (
+		cmdc  = make(chan interface{})
+		errc  = make(chan error)
+		linec = make(chan string)
+	)
+	c := &comm{
+		cmdc:    cmdc,
+		errc:    errc,
+		linec:   linec,
+		stdin:   stdin,
+		process: process,
+		log:     logger,
+		wait:    CommunicationTimeout,
+	}
+	go c.run()
+	go proc.ReadLines(stdout, linec, &c.readError)
+
+	e := &Engine{
+		cmdc: cmdc,
+		errc: errc,
+	}
+	for _, cmd := range []string{"xboard", "protover 2", "post", "easy", "force"} {
+		if err := e.Send(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Send sends a command to the engine.
+func (e *Engine) Send(cmd string) error {
+	e.cmdc <- cmd
+	return <-e.errc
+}
+
+// Stop implements engine.Engine.
+func (e *Engine) Stop() {
+	e.cmdc <- stop{}
+	<-e.errc
+}
+
+// Ping implements engine.Engine. Engines that do not support the ping feature
+// are assumed to be responding.
+func (e *Engine) Ping() error {
+	return e.Send("ping")
+}
+
+// SetTimeout sets the time to wait for a response from the engine, replacing
+// the CommunicationTimeout the engine was started with. If the engine fails to
+// respond in time, it is terminated.
+func (e *Engine) SetTimeout(d time.Duration) {
+	e.cmdc <- d
+	<-e.errc
+}
+
+// Quit implements engine.Engine. A search that is still running ends with an
+// Info reporting engine.ErrInterrupted. Quit returns the error, if any, of
+// closing the engine process, or an earlier communication error.
+func (e *Engine) Quit() error {
+	err := e.Send("quit")
+	close(e.cmdc)
+	return err
+}
+
+// Name returns the name of the engine, as announced with the myname feature.
+func (e *Engine) Name() string {
+	namec := make(chan string)
+	e.cmdc <- namec
+	if err := <-e.errc; err != nil {
+		return ""
+	}
+	return <-namec
+}
+
+// Search
+
+// SetPosition implements engine.Engine.
+func (e *Engine) SetPosition(board *chess.Board) {
+	e.Send("force")
+	e.Send("setboard " + board.Fen())
+	e.cmdc <- board
+	<-e.errc
+	e.board = board
+}
+
+// Search implements engine.Engine.
+func (e *Engine) Search() <-chan engine.Info {
+	return e.search(&search{analyze: true})
+}
+
+// SearchDepth implements engine.Engine.
+func (e *Engine) SearchDepth(depth int) <-chan engine.Info {
+	return e.search(&search{analyze: true, depth: depth})
+}
+
+// SearchTime implements engine.Engine.
+func (e *Engine) SearchTime(t time.Duration) <-chan engine.Info {
+	return e.search(&search{analyze: true, time: t})
+}
+
+// SearchNodes implements engine.Engine.
+func (e *Engine) SearchNodes(nodes int64) <-chan engine.Info {
+	return e.search(&search{analyze: true, nodes: nodes})
+}
+
+// SearchClock implements engine.Engine. The engine thinks on its move as if
+// playing a game and the search ends with the move it plays.
+func (e *Engine) SearchClock(wtime, btime, winc, binc time.Duration, movesToGo int) <-chan engine.Info {
+	own, opp, inc := wtime, btime, winc
+	if e.board != nil && e.board.SideToMove == chess.Black {
+		own, opp, inc = btime, wtime, binc
+	}
+	return e.search(&search{clock: []string{
+		fmt.Sprintf("level %d %d:%02d %d", movesToGo, own/time.Minute, own%time.Minute/time.Second, inc/time.Second),
+		fmt.Sprintf("time %d", own/(10*time.Millisecond)),
+		fmt.Sprintf("otim %d", opp/(10*time.Millisecond)),
+	}})
+}
+
+func (e *Engine) search(s *search) <-chan engine.Info {
+	infoc := make(chan engine.Info, 1)
+	s.infoc = infoc
+	// Sync to ensure that no debris is sent on the Info channel.
+	e.Ping()
+	// The communicator starts the search.
+	e.cmdc <- s
+	if err := <-e.errc; err != nil {
+		infoc <- Info{err: err}
+		close(infoc)
+	}
+	return infoc
+}
+
+// Options implements engine.Engine. The options are those announced with the
+// option feature.
+func (e *Engine) Options() map[string]engine.Option {
+	optc := make(chan map[string]engine.Option)
+	e.cmdc <- optc
+	if err := <-e.errc; err != nil {
+		return nil
+	}
+	return <-optc
+}
+
+// Communication
+
+type comm struct {
+	cmdc      chan interface{}         // request channel
+	errc      chan error               // response channel
+	err       error                    // error state of the communication
+	linec     <-chan string            // engine output lines
+	search    *search                  // running search
+	board     *chess.Board             // position being searched
+	process   io.Closer                // the thing to close on error
+	stdin     io.Writer                // for sending commands
+	log       *log.Logger              // communication log
+	features  map[string]string        // features announced by the engine
+	options   map[string]engine.Option // engine options
+	readError error                    // error returned by readLines
+	wait      time.Duration            // communication timeout
+	ping      int                      // number of the last ping sent
+}
+
+// search holds the state of a running search.
+type search struct {
+	infoc   chan engine.Info
+	analyze bool          // analyze mode, rather than thinking with go
+	clock   []string      // time control commands, for go
+	depth   int           // analyze: stop at this depth, if not zero
+	nodes   int64         // analyze: stop after this many nodes, if not zero
+	time    time.Duration // analyze: stop after this time, if not zero
+	timer   *time.Timer   // time limit
+	last    string        // analyze: last thinking output
+}
+
+// stop is sent to comm to stop a search.
+type stop struct{}
+
+// send writes a command to the engine, closing it on error.
+func (c *comm) send(cmd string) {
+	if c.err != nil {
+		return
+	}
+	if c.log != nil {
+		c.log.Println(">", cmd)
+	}
+	if err := proc.WriteLine(c.stdin, cmd, c.wait); err != nil {
+		c.close(err)
+	}
+}
+
+// close closes the engine process, setting the error state to err. It returns
+// the error from closing the process.
+func (c *comm) close(err error) error {
+	c.err = err
+	cerr := c.process.Close()
+	c.endSearch(Info{err: err})
+	return cerr
+}
+
+// endSearch sends the final Info of the running search, if any, and closes
+// its channel.
+func (c *comm) endSearch(last Info) {
+	if c.search == nil {
+		return
+	}
+	if t := c.search.timer; t != nil {
+		t.Stop()
+	}
+	c.search.infoc <- last
+	close(c.search.infoc)
+	c.search = nil
+}
+
+// stopSearch stops the running search. In analyze mode the search ends with
+// the first move of the last principal variation as the best move; a search
+// started with go ends when the engine plays its move.
+func (c *comm) stopSearch() {
+	switch s := c.search; {
+	case s == nil:
+		return
+	case s.analyze:
+		c.send("exit")
+		best := "(none)"
+		if moves := (Info{line: s.last, board: c.board}).pvFields(); len(moves) > 0 {
+			best = moves[0]
+		}
+		c.endSearch(Info{move: best, board: c.board})
+	default:
+		c.send("?")
+	}
+}
+
+func (c *comm) run() {
+	var timeout <-chan time.Time
+	var pong string // reply awaited from the engine
+	initialised := false
+	quitting := false
+	c.features = make(map[string]string)
+	c.options = make(map[string]engine.Option)
+
+loop:
+	var searchTimer <-chan time.Time
+	if c.search != nil && c.search.timer != nil {
+		searchTimer = c.search.timer.C
+	}
+	select {
+	case in, ok := <-c.cmdc:
+		if !ok {
+			return
+		}
+		errc := c.errc
+		if c.err == nil {
+			switch v := in.(type) {
+			case string:
+				switch v {
+				case "protover 2":
+					pong = "done"
+				case "ping":
+					if c.features["ping"] != "1" {
+						break // nothing to wait for
+					}
+					c.ping++
+					v = fmt.Sprintf("ping %d", c.ping)
+					pong = fmt.Sprintf("pong %d", c.ping)
+				case "quit":
+					c.endSearch(Info{err: engine.ErrInterrupted})
+					quitting = true
+				}
+				if v == "ping" {
+					break
+				}
+				c.send(v)
+				if c.err == nil && (pong != "" || quitting) {
+					timeout = time.After(c.wait)
+					errc = nil
+				}
+			case stop:
+				c.stopSearch()
+			case *chess.Board:
+				c.board = v
+			case time.Duration:
+				c.wait = v
+			case *search:
+				if c.board == nil {
+					c.err = errors.New("SetPosition not called before search")
+					break
+				}
+				c.search = v
+				if v.analyze {
+					c.send("analyze")
+					if v.time > 0 {
+						v.timer = time.NewTimer(v.time)
+					}
+				} else {
+					for _, cmd := range v.clock {
+						c.send(cmd)
+					}
+					c.send("go")
+				}
+			case chan map[string]engine.Option:
+				errc <- nil
+				errc = nil
+				v <- c.options
+			case chan string:
+				errc <- nil
+				errc = nil
+				v <- c.features["myname"]
+			}
+		}
+		if errc != nil {
+			errc <- c.err
+		}
+	case line, ok := <-c.linec:
+		if !ok {
+			c.linec = nil
+			reply := c.err
+			if c.err == nil {
+				if quitting {
+					// the reply to quit is the result of
+					// cleaning up the process
+					reply = c.close(engine.ErrExited)
+				} else {
+					c.close(proc.ExitError(c.process, c.readError))
+					reply = c.err
+				}
+			}
+			if timeout != nil {
+				c.errc <- reply
+				timeout = nil
+			}
+			break
+		}
+		if c.log != nil {
+			c.log.Println("|", line)
+		}
+		switch field := proc.Tokenise(line); field.Next() {
+		case "feature":
+			for _, f := range parseFeatures(field.Remainder()) {
+				c.feature(f[0], f[1])
+			}
+			if c.features["done"] == "1" && pong == "done" && timeout != nil {
+				c.errc <- nil
+				pong, timeout = "", nil
+				initialised = true
+			} else if c.features["done"] == "0" && pong == "done" {
+				// the engine needs more time to start up
+				timeout = time.After(c.wait)
+			}
+		case "pong":
+			if line == pong && timeout != nil {
+				c.errc <- nil
+				pong, timeout = "", nil
+			}
+		case "move":
+			if c.search != nil && !c.search.analyze {
+				c.endSearch(Info{move: field.Next(), board: c.board})
+				// don't let the engine go on playing
+				c.send("force")
+			}
+		default:
+			if c.search != nil && isThinking(line) {
+				info := Info{line: line, board: c.board}
+				c.search.infoc <- info
+				if c.search.analyze {
+					c.search.last = line
+					stats := info.Stats()
+					if (c.search.depth > 0 && stats.Depth >= c.search.depth) ||
+						(c.search.nodes > 0 && int64(stats.Nodes) >= c.search.nodes) {
+						c.stopSearch()
+					}
+				}
+			}
+		}
+	case <-searchTimer:
+		c.search.timer = nil
+		c.stopSearch()
+	case <-timeout:
+		timeout = nil
+		if !initialised && pong == "done" {
+			// a protocol version 1 engine, which does not
+			// announce features
+			pong = ""
+			initialised = true
+			c.errc <- nil
+			break
+		}
+		c.close(engine.ErrTimeout)
+		c.errc <- c.err
+	}
+
+	goto loop
+}
+
+// knownFeatures lists the features of protocol version 2, which are
+// accepted; others are rejected.
+var knownFeatures = map[string]bool{
+	"analyze": true, "colors": true, "debug": true, "draw": true,
+	"egt": true, "exclude": true, "ics": true, "memory": true,
+	"myname": true, "name": true, "nps": true, "option": true,
+	"pause": true, "ping": true, "playother": true, "reuse": true,
+	"san": true, "setboard": true, "setscore": true, "sigint": true,
+	"sigterm": true, "smp": true, "time": true, "usermove": true,
+	"variants": true,
+}
+
+// feature records a feature announced by the engine and replies whether it
+// is accepted.
+func (c *comm) feature(name, value string) {
+	switch {
+	case name == "done":
+		c.features[name] = value
+		return
+	case !knownFeatures[name]:
+		c.send("rejected " + name)
+		return
+	case name == "option":
+		c.parseOption(value)
+	default:
+		c.features[name] = value
+	}
+	c.send("accepted " + name)
+}
+
+// parseFeatures splits the arguments of a feature command into name/value
+// pairs. Values may be quoted: feature myname="Fake Engine 1.0".
+func parseFeatures(s string) [][2]string {
+	var features [][2]string
+	for {
+		s = strings.TrimSpace(s)
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return features
+		}
+		name, rest := s[:eq], s[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, s = rest[1:], ""
+			if end := strings.IndexByte(value, '"'); end >= 0 {
+				value, s = value[:end], value[end+1:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			value, s = rest[:end], rest[end:]
+		}
+		features = append(features, [2]string{name, value})
+	}
+}
+
+// parseOption parses the value of an option feature, such as
+// "Hash -spin 64 1 1024".
+func (c *comm) parseOption(s string) {
+	i := strings.Index(s, " -")
+	if i < 0 {
+		return
+	}
+	name := strings.TrimSpace(s[:i])
+	field := proc.Tokenise(s[i+2:])
+	typ := field.Next()
+	set := proc.NewSetter(c.cmdc, c.errc, setOption)
+
+	switch typ {
+	case "string", "file", "path":
+		c.options[name] = proc.NewStringOption(set, name, field.Remainder())
+	case "check":
+		c.options[name] = proc.NewBoolOption(set, name, field.Next() == "1")
+	case "spin", "slider":
+		def, _ := strconv.Atoi(field.Next())
+		min, _ := strconv.Atoi(field.Next())
+		max, _ := strconv.Atoi(field.Next())
+		c.options[name] = proc.NewIntOption(set, name, def, min, max)
+	case "combo":
+		var def string
+		var choices []string
+		for _, choice := range strings.Split(field.Remainder(), "///") {
+			choice = strings.TrimSpace(choice)
+			if strings.HasPrefix(choice, "*") {
+				choice = choice[1:]
+				def = choice
+			}
+			choices = append(choices, choice)
+		}
+		if def == "" && len(choices) > 0 {
+			def = choices[0]
+		}
+		c.options[name] = proc.NewComboOption(set, name, def, choices)
+	case "button", "save", "reset":
+		c.options[name] = proc.NewButtonOption(set, name)
+	}
+}
+
+// Info
+
+// Info is an engine.Info for an XBoard engine. It holds either a line of
+// thinking output, "depth score time nodes pv", or the best move.
+type Info struct {
+	line  string // thinking output
+	move  string // best move, for the last Info of a search
+	board *chess.Board
+	err   error
+}
+
+func (i Info) Err() error { return i.err }
+
+func (i Info) BestMove() (chess.Move, bool) {
+	if i.move == "" {
+		return chess.NullMove, false
+	}
+	m, err := i.board.ParseMove(i.move)
+	if err != nil {
+		m = chess.NullMove
+	}
+	return m, true
+}
+
+// mateScore is the score from which XBoard engines report mates: 100000+n
+// for mate in n moves.
+const mateScore = 100000
+
+// Pv implements engine.Info. Scores are reported by the engine from the side
+// to move's point of view, and are converted to White's point of view.
+func (i Info) Pv() *engine.Pv {
+	f := strings.Fields(i.line)
+	if len(f) < 4 {
+		return nil
+	}
+	score, err := strconv.Atoi(f[1])
+	if err != nil {
+		return nil
+	}
+	mate := false
+	switch {
+	case score >= mateScore:
+		score, mate = score-mateScore, true
+	case score <= -mateScore:
+		score, mate = score+mateScore, true
+	}
+	if i.board.SideToMove == chess.Black {
+		score = -score
+	}
+	moves := make([]chess.Move, 0, len(f)-4)
+	b := i.board
+	for _, s := range i.pvFields() {
+		m, err := b.ParseMove(s)
+		if err != nil {
+			break
+		}
+		moves = append(moves, m)
+		b = b.MakeMove(m)
+	}
+	return &engine.Pv{Moves: moves, Score: score, Mate: mate, SideToMove: i.board.SideToMove}
+}
+
+// pvFields returns the moves of the principal variation of thinking output,
+// leaving out move numbers such as "12." or "12...".
+func (i Info) pvFields() []string {
+	f := strings.Fields(i.line)
+	if len(f) < 4 {
+		return nil
+	}
+	var moves []string
+	for _, s := range f[4:] {
+		if n := strings.TrimRight(s, "."); n != s {
+			if _, err := strconv.Atoi(n); err == nil {
+				continue
+			}
+		}
+		moves = append(moves, s)
+	}
+	return moves
+}
+
+// Stats implements engine.Info. The engine reports depth, time and nodes.
+func (i Info) Stats() *engine.Stats {
+	f := strings.Fields(i.line)
+	if len(f) < 4 {
+		return &engine.Stats{}
+	}
+	depth, _ := strconv.Atoi(strings.TrimRight(f[0], ".&"))
+	centis, _ := strconv.Atoi(f[2])
+	nodes, _ := strconv.Atoi(f[3])
+	return &engine.Stats{
+		Depth: depth,
+		Time:  time.Duration(centis) * 10 * time.Millisecond,
+		Nodes: nodes,
+	}
+}
+
+// Raw returns the full line as sent by the engine, or the empty string for
+// the last Info of a search.
+func (i Info) Raw() string {
+	return i.line
+}
+
+// isThinking returns whether line is thinking output: it starts with four
+// numbers, the depth (possibly followed by a '.' or '&'), score, time and
+// nodes.
+func isThinking(line string) bool {
+	f := strings.Fields(line)
+	if len(f) < 4 {
+		return false
+	}
+	f[0] = strings.TrimRight(f[0], ".&")
+	for _, s := range f[:4] {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Options
+
+// The option types implement the option interfaces of package engine.
+type (
+	StringOption = proc.StringOption
+	IntOption    = proc.IntOption
+	BoolOption   = proc.BoolOption
+	ComboOption  = proc.ComboOption
+	ButtonOption = proc.ButtonOption
+)
+
+// setOption returns the option command setting an option to value, or
+// pressing a button if value is nil. Check options are set to 1 or 0.
+func setOption(name string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "option " + name
+	case bool:
+		if v {
+			return fmt.Sprintf("option %s=1", name)
+		}
+		return fmt.Sprintf("option %s=0", name)
+	}
+	return fmt.Sprintf("option %s=%v", name, value)
+}
@@ -0,0 +1,251 @@
+package xboard
+
+import (
+	"fmt"
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/engine"
+	"github.com/malbrecht/chess/engine/internal/enginetest"
+	"github.com/malbrecht/chess/engine/internal/proc"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	CommunicationTimeout = 1 * time.Second
+}
+
+var optionTests = []enginetest.OptionTest{
+	{Decl: "Hash -spin 16 1 1024", Name: "Hash", Set: "64", Value: 64, Command: "option Hash=64"},
+	{Decl: "Book -check 1", Name: "Book", Set: "false", Value: false, Command: "option Book=0"},
+	{Decl: "Style -combo Solid /// *Normal /// Risky", Name: "Style", Set: "risky", Value: "Risky", Command: "option Style=Risky"},
+	{Decl: "Book File -file book.bin", Name: "Book File", Set: "my book.bin", Value: "my book.bin",
+		Command: "option Book File=my book.bin"},
+	// setting a button presses it, whatever the value
+	{Decl: "Clear Hash -button", Name: "Clear Hash", Set: "x", Command: "option Clear Hash"},
+}
+
+// The fake engine's position is after 1. e4, so scores are from Black's point
+// of view.
+const fakePosition = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+
+type thinkingTest struct {
+	line  string
+	score int // from White's point of view
+	mate  bool
+	pv    string
+	stats engine.Stats
+}
+
+var thinkingTests = []thinkingTest{
+	{"1 -10 1 20 e5", 10, false, "e5", engine.Stats{Depth: 1, Time: 10 * time.Millisecond, Nodes: 20}},
+	{"5 29 150 12000 e5 2. Nf3 Nc6", -29, false, "e5 Nf3 Nc6",
+		engine.Stats{Depth: 5, Time: 1500 * time.Millisecond, Nodes: 12000}},
+	{"12& -100005 500 150000 c5 2. Nf3 Qb6 xx", 5, true, "c5 Nf3 Qb6",
+		engine.Stats{Depth: 12, Time: 5 * time.Second, Nodes: 150000}},
+}
+
+// fakeEngine is the command handler of a fake engine, see enginetest.Start.
+func fakeEngine(line string, out chan<- string) bool {
+	switch field := proc.Tokenise(line); field.Next() {
+	case "protover":
+		out <- `feature myname="Fake Engine 1.0" setboard=1 ping=1 bogus=1`
+		for _, o := range optionTests {
+			out <- fmt.Sprintf(`feature option="%s"`, o.Decl)
+		}
+		out <- "feature done=1"
+	case "ping":
+		out <- "pong " + field.Remainder()
+	case "analyze":
+		// analyze until "exit"
+		out <- "telluser analyzing"
+		for _, t := range thinkingTests {
+			out <- t.line
+		}
+	case "go":
+		for _, t := range thinkingTests {
+			out <- t.line
+		}
+		out <- "move c5"
+	case "quit":
+		return false
+	}
+	return true
+}
+
+// startFakeEngine starts a fake engine and returns an Engine communicating
+// with it, set up to search the fake engine's position, and the recorder of
+// the commands the fake engine receives.
+func startFakeEngine(t *testing.T) (*Engine, *enginetest.Recorder) {
+	var logger *log.Logger //= log.New(os.Stdout, "", log.LstdFlags)
+
+	stdout, stdin, rec := enginetest.Start(fakeEngine)
+	e, err := initialise(stdout, stdin, stdin, logger)
+	if err != nil {
+		t.Fatal("engine initialisation failed:", err)
+	}
+	e.SetPosition(chess.MustParseFen(fakePosition))
+	return e, rec
+}
+
+// drain reads infoc until it is closed and returns all Infos.
+func drain(infoc <-chan engine.Info) []engine.Info {
+	var infos []engine.Info
+	for info := range infoc {
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// checkSearch checks that infos holds the thinking output of the first n
+// thinkingTests, followed by the best move, given in SAN.
+func checkSearch(t *testing.T, name string, infos []engine.Info, n int, best string) {
+	if len(infos) != n+1 {
+		t.Errorf("%s: got %d infos, want %d", name, len(infos), n+1)
+		return
+	}
+	for i, info := range infos[:n] {
+		if info.Err() != nil {
+			t.Errorf("%s: info %d: %v", name, i, info.Err())
+		}
+		if _, ok := info.BestMove(); ok {
+			t.Errorf("%s: info %d has a best move", name, i)
+		}
+	}
+	last := infos[n]
+	m, ok := last.BestMove()
+	if last.Err() != nil || !ok {
+		t.Errorf("%s: got last info %v, want best move", name, last)
+		return
+	}
+	if san := m.San(chess.MustParseFen(fakePosition)); san != best {
+		t.Errorf("%s: got best move %s, want %s", name, san, best)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	if name := e.Name(); name != "Fake Engine 1.0" {
+		t.Errorf("got name %q", name)
+	}
+	for _, cmd := range []string{"accepted myname", "accepted setboard", "rejected bogus", "setboard " + fakePosition} {
+		if len(rec.Commands(cmd)) != 1 {
+			t.Errorf("command %q not sent", cmd)
+		}
+	}
+
+	checkSearch(t, "SearchDepth", drain(e.SearchDepth(5)), 2, "e5")
+	if len(rec.Commands("exit")) != 1 {
+		t.Error("analyze mode not left at the search depth")
+	}
+	checkSearch(t, "SearchNodes", drain(e.SearchNodes(20)), 1, "e5")
+	checkSearch(t, "SearchTime", drain(e.SearchTime(50*time.Millisecond)), 3, "c5")
+
+	// an infinite search
+	infoc := e.Search()
+	var infos []engine.Info
+	for range thinkingTests {
+		infos = append(infos, <-infoc)
+	}
+	e.Stop()
+	checkSearch(t, "Search", append(infos, drain(infoc)...), 3, "c5")
+
+	// a search in game mode
+	checkSearch(t, "SearchClock", drain(e.SearchClock(time.Minute, 90*time.Second, 0, time.Second, 20)), 3, "c5")
+	e.Ping() // wait for the engine to receive force
+	want := []string{"level 20 1:30 1", "time 9000", "otim 6000", "go", "force"}
+	var got []string
+	for _, cmd := range rec.Commands("") {
+		for _, w := range want {
+			if strings.HasPrefix(cmd, w) {
+				got = append(got, cmd)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got[len(got)-len(want):], want) {
+		t.Errorf("SearchClock: got commands %q, want %q", got, want)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	e, rec := startFakeEngine(t)
+	defer e.Quit()
+
+	opts := e.Options()
+	if len(opts) != len(optionTests) {
+		t.Errorf("got %d options, want %d", len(opts), len(optionTests))
+	}
+	enginetest.CheckOptions(t, opts, optionTests, rec, e.Ping)
+	if style := opts["Style"].(*ComboOption); style.Default() != "Normal" {
+		t.Errorf("combo option: got default %q, want Normal", style.Default())
+	}
+}
+
+func TestThinking(t *testing.T) {
+	board := chess.MustParseFen(fakePosition)
+	for _, test := range thinkingTests {
+		info := Info{line: test.line, board: board}
+		if !isThinking(test.line) {
+			t.Errorf("%s: not recognised as thinking output", test.line)
+		}
+		pv := info.Pv()
+		if pv == nil {
+			t.Errorf("%s: no pv", test.line)
+			continue
+		}
+		if pv.Score != test.score || pv.Mate != test.mate {
+			t.Errorf("%s: got score %d mate %v, want %d mate %v", test.line, pv.Score, pv.Mate, test.score, test.mate)
+		}
+		var moves []string
+		b := board
+		for _, m := range pv.Moves {
+			moves = append(moves, m.San(b))
+			b = b.MakeMove(m)
+		}
+		if got := strings.Join(moves, " "); got != test.pv {
+			t.Errorf("%s: got pv %q, want %q", test.line, got, test.pv)
+		}
+		if stats := info.Stats(); *stats != test.stats {
+			t.Errorf("%s: got stats %+v, want %+v", test.line, *stats, test.stats)
+		}
+	}
+	for _, line := range []string{"telluser 1 2 3 4", "1 2 3", "move e2e4", "Illegal move: e9"} {
+		if isThinking(line) {
+			t.Errorf("%q recognised as thinking output", line)
+		}
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	got := parseFeatures(`myname="Fake 1.0" ping=1  option="Book File -file a b.bin" done=0 bad="open`)
+	want := [][2]string{
+		{"myname", "Fake 1.0"}, {"ping", "1"}, {"option", "Book File -file a b.bin"},
+		{"done", "0"}, {"bad", "open"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQuitDuringSearch(t *testing.T) {
+	e, _ := startFakeEngine(t)
+	infoc := e.Search()
+	<-infoc // the search is running
+	done := make(chan []engine.Info)
+	go func() { done <- drain(infoc) }()
+	if err := e.Quit(); err != nil {
+		t.Errorf("Quit: %v", err)
+	}
+	select {
+	case infos := <-done:
+		if last := infos[len(infos)-1]; last.Err() != engine.ErrInterrupted {
+			t.Errorf("got last info %v, want error %v", last, engine.ErrInterrupted)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("info channel not closed after Quit")
+	}
+}
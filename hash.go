@@ -39,17 +39,8 @@ func (b *Board) Hash() (hash uint64) {
 	if b.CastleSq[BlackOOO] != NoSquare {
 		hash ^= castleHash[3]
 	}
-	if b.EpSquare != NoSquare {
-		// check that there actually is a pawn to do the capturing
-		var sq Sq
-		if b.SideToMove == White {
-			sq = Square(b.EpSquare.File(), Rank5)
-		} else {
-			sq = Square(b.EpSquare.File(), Rank4)
-		}
-		if b.find(b.my(Pawn), sq-1, sq+1) != NoSquare {
-			hash ^= epHash[b.EpSquare.File()]
-		}
+	if b.hasEpCapturer() {
+		hash ^= epHash[b.EpSquare.File()]
 	}
 	if b.SideToMove == White {
 		hash ^= stmHash[0]
@@ -57,6 +48,49 @@ func (b *Board) Hash() (hash uint64) {
 	return hash
 }
 
+// hasEpCapturer returns whether EpSquare is set and a pawn of the side to move
+// stands next to the pawn that can be captured en passant. As in Polyglot,
+// whether the capture is legal does not matter.
+func (b *Board) hasEpCapturer() bool {
+	if b.EpSquare == NoSquare {
+		return false
+	}
+	var sq Sq
+	if b.SideToMove == White {
+		sq = Square(b.EpSquare.File(), Rank5)
+	} else {
+		sq = Square(b.EpSquare.File(), Rank4)
+	}
+	return b.find(b.my(Pawn), sq-1, sq+1) != NoSquare
+}
+
+// The keys below are the Polyglot keys used by Hash, for maintaining a
+// compatible hash incrementally: the hash of a position is the XOR of the keys
+// of its pieces, its castling rights, its en-passant file (only if a pawn can
+// actually capture en passant) and SideKey if White is to move.
+
+// PieceKey returns the hash key for piece p on square sq.
+func PieceKey(p Piece, sq Sq) uint64 {
+	return pieceHash[64*polyglotPiece[p]+int(sq)]
+}
+
+// CastleKey returns the hash key for a castling right (WhiteOO, WhiteOOO,
+// BlackOO or BlackOOO).
+func CastleKey(right int) uint64 {
+	return castleHash[[...]int{WhiteOO: 0, WhiteOOO: 1, BlackOO: 2, BlackOOO: 3}[right]]
+}
+
+// EpFileKey returns the hash key for an en-passant capture on the given file
+// (0-7).
+func EpFileKey(file int) uint64 {
+	return epHash[file]
+}
+
+// SideKey returns the hash key for White to move.
+func SideKey() uint64 {
+	return stmHash[0]
+}
+
 var random64 = [...]uint64{
 	0x9D39247E33776D41, 0x2AF7398005AAA5C7, 0x44DB015024623547, 0x9C15F73E62A76AE2,
 	0x75834465489C0C89, 0x3290AC3A203001BF, 0x0FBBAD1F61042279, 0xE83A908FF2FB60CA,
@@ -37,3 +37,103 @@ func TestHash(t *testing.T) {
 		}
 	}
 }
+
+func TestHashKeys(t *testing.T) {
+	// pieceHash combines the keys for the pieces and all castling rights.
+	pieceHash := func(b *Board) (hash uint64) {
+		for sq, p := range b.Piece {
+			if p != NoPiece {
+				hash ^= PieceKey(p, Sq(sq))
+			}
+		}
+		for _, right := range []int{WhiteOO, WhiteOOO, BlackOO, BlackOOO} {
+			hash ^= CastleKey(right)
+		}
+		return hash
+	}
+	b := MustParseFen(hashTests[0].fen)
+	if hash, want := pieceHash(b)^SideKey(), hashTests[0].hash; hash != want {
+		t.Errorf("start position: got %x, want %x", hash, want)
+	}
+	// 1. e4 d5 2. e5 f5 allows an en-passant capture on the f-file
+	b = MustParseFen(hashTests[4].fen)
+	if hash, want := pieceHash(b)^EpFileKey(5)^SideKey(), hashTests[4].hash; hash != want {
+		t.Errorf("%s: got %x, want %x", b.Fen(), hash, want)
+	}
+}
+
+func TestTransTable(t *testing.T) {
+	// Force a hash collision between the start position and the one with
+	// the g1 knight on h3 by giving both squares the same key.
+	start := MustParseFen(hashTests[0].fen)
+	nh3 := MustParseFen("rnbqkbnr/pppppppp/8/8/8/7N/PPPPPPPP/RNBQKB1R w KQkq - 0 1")
+	h3 := 64*polyglotPiece[WN] + int(H3)
+	defer func(key uint64) { pieceHash[h3] = key }(pieceHash[h3])
+	pieceHash[h3] = PieceKey(WN, G1)
+	if start.Hash() != nh3.Hash() || start.Equal(nh3) {
+		t.Fatal("no hash collision")
+	}
+
+	var tt TransTable[string]
+	if _, ok := tt.Get(start); ok {
+		t.Error("Get on empty table succeeded")
+	}
+	tt.Put(start, "start")
+	if v, ok := tt.Get(nh3); ok {
+		t.Errorf("colliding position: got %q", v)
+	}
+	tt.Put(nh3, "Nh3")
+	tt.Put(start, "initial")
+	if v, ok := tt.Get(start); !ok || v != "initial" {
+		t.Errorf("start: got %q, %v", v, ok)
+	}
+	if v, ok := tt.Get(nh3); !ok || v != "Nh3" {
+		t.Errorf("Nh3: got %q, %v", v, ok)
+	}
+	if n := tt.Len(); n != 2 {
+		t.Errorf("got %d entries, want 2", n)
+	}
+	// the move counters do not matter
+	moved := *start
+	moved.MoveNr = 20
+	if v, ok := tt.Get(&moved); !ok || v != "initial" {
+		t.Errorf("start with other move number: got %q, %v", v, ok)
+	}
+}
+
+func TestTransTableEnPassant(t *testing.T) {
+	// After 1. e4 no pawn can capture on e3, so the position is the same
+	// whether or not the en-passant square is set.
+	e4 := MustParseFen("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	e4ep := *e4
+	e4ep.EpSquare = E3
+	if !e4.Equal(&e4ep) {
+		t.Errorf("%s and %s are not equal", e4.Fen(), e4ep.RawFen())
+	}
+	var tt TransTable[string]
+	tt.Put(e4, "e4")
+	tt.Put(&e4ep, "e4 e.p.")
+	if v, ok := tt.Get(e4); !ok || v != "e4 e.p." {
+		t.Errorf("e4: got %q, %v", v, ok)
+	}
+	if n := tt.Len(); n != 1 {
+		t.Errorf("got %d entries, want 1", n)
+	}
+
+	// 1. e4 d5 2. e5 f5 allows exf6, so the en-passant square counts.
+	f5 := MustParseFen(hashTests[4].fen)
+	noEp := *f5
+	noEp.EpSquare = NoSquare
+	if f5.Equal(&noEp) || noEp.Equal(f5) {
+		t.Errorf("%s equals the position without en-passant square", f5.Fen())
+	}
+
+	// The e5 pawn is pinned and cannot capture on d6, but Hash still counts
+	// the en-passant square, and so does Equal.
+	pinned := MustParseFen("4r2k/8/8/3pP3/8/8/8/4K3 w - - 0 2")
+	pinnedEp := *pinned
+	pinnedEp.EpSquare = D6
+	if equal, sameHash := pinned.Equal(&pinnedEp), pinned.Hash() == pinnedEp.Hash(); equal != sameHash {
+		t.Errorf("pinned capturer: Equal is %v, but equal hashes %v", equal, sameHash)
+	}
+}
@@ -0,0 +1,43 @@
+package chess
+
+// History records the positions of a game by their hashes, to detect
+// repetitions without keeping the boards. Its zero value is an empty history
+// ready for use.
+type History struct {
+	hashes []uint64
+}
+
+// Push adds a position to the history. Push every position of the game,
+// including the current one.
+func (h *History) Push(b *Board) {
+	h.hashes = append(h.hashes, b.Hash())
+}
+
+// IsRepetition returns whether position b occurs at least times times in the
+// history. Only the positions since the last pawn move or capture are
+// considered, as earlier positions cannot repeat; this assumes that b is the
+// last position pushed.
+func (h *History) IsRepetition(b *Board, times int) bool {
+	hash := b.Hash()
+	count := 0
+	for i := len(h.hashes) - 1; i >= 0 && i >= len(h.hashes)-1-b.Rule50; i-- {
+		if h.hashes[i] == hash {
+			count++
+		}
+	}
+	return count >= times
+}
+
+// GameOver returns whether the game is over in position b, the last position
+// pushed, and if so the result ("1-0", "0-1" or "1/2-1/2"). If the game is not
+// over the result is "", as for Board.Outcome. In addition to the rules of Board.Outcome, draws by
+// threefold repetition are included, which is what a search typically needs.
+func (h *History) GameOver(b *Board) (over bool, result string) {
+	if over, result := b.Outcome(); over {
+		return over, result
+	}
+	if h.IsRepetition(b, 3) {
+		return true, "1/2-1/2"
+	}
+	return false, ""
+}
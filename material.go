@@ -0,0 +1,68 @@
+package chess
+
+// PieceValues holds the conventional value of each piece type in pawn units.
+// The king has no material value.
+var PieceValues = []int{
+	Pawn:   1,
+	Knight: 3,
+	Bishop: 3,
+	Rook:   5,
+	Queen:  9,
+	King:   0,
+}
+
+// phaseWeights holds the contribution of each piece type to the game phase:
+// 1 for a minor piece, 2 for a rook and 4 for a queen, so that the starting
+// position adds up to MaxPhase.
+var phaseWeights = []int{
+	Knight: 1,
+	Bishop: 1,
+	Rook:   2,
+	Queen:  4,
+	King:   0,
+}
+
+// MaxPhase is the game phase of a position with all non-pawn material on the
+// board.
+const MaxPhase = 24
+
+// Count returns the number of pieces of the given kind (e.g. WN) on the board.
+func (b *Board) Count(piece Piece) int {
+	n := 0
+	for _, p := range b.Piece {
+		if p == piece {
+			n++
+		}
+	}
+	return n
+}
+
+// Material returns the summed value (see PieceValues) of the pieces of the
+// given color.
+func (b *Board) Material(color int) int {
+	sum := 0
+	for _, p := range b.Piece {
+		if p != NoPiece && p.Color() == color {
+			sum += PieceValues[p.Type()]
+		}
+	}
+	return sum
+}
+
+// Phase returns the game phase, based on the non-pawn material left on the
+// board. Knights and bishops count 1, rooks 2 and queens 4, giving MaxPhase
+// (24) for the starting position and 0 when only kings and pawns remain. The
+// result is capped at MaxPhase, which can otherwise be exceeded after
+// promotions.
+func (b *Board) Phase() int {
+	phase := 0
+	for _, p := range b.Piece {
+		if p != NoPiece {
+			phase += phaseWeights[p.Type()]
+		}
+	}
+	if phase > MaxPhase {
+		phase = MaxPhase
+	}
+	return phase
+}
@@ -2,7 +2,9 @@ package chess
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 type Move struct {
@@ -13,20 +15,73 @@ type Move struct {
 
 var NullMove = Move{}
 
-// isLegal checks the legality of a pseudo-legal move.
+// Errors returned by ParseMove and ParseMoveVerify.
+var (
+	// ErrNoSuchMove means that no legal move matches the notation.
+	ErrNoSuchMove = errors.New("invalid move")
+	// ErrAmbiguousMove means that several legal moves match the notation,
+	// as "Nd7" when knights on b8 and f6 can both go to d7.
+	ErrAmbiguousMove = errors.New("ambiguous move")
+	// ErrCheckMismatch means that the check or mate symbol of the notation
+	// does not match the position after the move, as "Qxf7#" when the
+	// move only gives check.
+	ErrCheckMismatch = errors.New("check symbol does not match move")
+)
+
+// figurinesToLetters replaces the Figurines of either color in s by upper
+// case piece letters. Pawn figurines are dropped, as pawn moves have no piece
+// letter.
+func figurinesToLetters(s string) string {
+	if utf8.RuneCountInString(s) == len(s) {
+		return s // plain ASCII
+	}
+	return strings.Map(func(r rune) rune {
+		for p := WP; p < len(Figurines); p++ {
+			if Figurines[p] != r {
+				continue
+			}
+			if Piece(p).Type() == Pawn {
+				return -1
+			}
+			return PieceLetters[p&^0x01]
+		}
+		return r
+	}, s)
+}
+
+// isLegal checks the legality of a pseudo-legal move: it must not leave the
+// mover's king attacked, and a castling king must not pass through or start
+// from an attacked square.
 func (m Move) isLegal(b *Board) bool {
 	b = b.MakeMove(m)
-	_, illegal := b.pseudoLegalMoves()
-	return !illegal
+	checkFrom, checkTo := b.checkFrom, b.checkTo
+	if checkFrom == A1 && checkTo == A1 {
+		checkFrom = b.find(b.opp(King), A1, H8)
+		checkTo = checkFrom
+		if checkFrom == NoSquare {
+			return true
+		}
+	}
+	for sq := checkFrom; sq <= checkTo; sq++ {
+		if b.attacked(sq) {
+			return false
+		}
+	}
+	return true
 }
 
 // ParseMove parses a move in algebraic notation. The parser is forgiving and
 // will accept varying forms of algebraic notation, including slightly
 // incorrect notations (for instance with uncapitalized piece characters).
 // Examples: e4, Bb5, cxd3, O-O, 0-0-0, Rae1+, f8=Q, f8/Q, e2-e4, Bf1-b5, e2e4,
-// f1b5, e1g1 (castling), f7f8q.
+// f1b5, e1g1 (castling), f7f8q, as well as figurine notation as written by
+// Fan (♘f3, e8=♕). An en-passant marker following the move
+// ("exd6 e.p.", "exd6ep") is ignored. A null move is written as "--" or "Z0".
+// If no legal move matches, ErrNoSuchMove is returned; if more than one
+// matches, ErrAmbiguousMove.
 func (b *Board) ParseMove(s string) (Move, error) {
-	if s == "--" {
+	s = stripEnPassant(figurinesToLetters(s))
+	if s == "--" || s == "Z0" {
 		return NullMove, nil
 	}
 	var (
@@ -35,7 +90,7 @@ func (b *Board) ParseMove(s string) (Move, error) {
 		piece     = NoPiece
 		promotion = NoPiece
 		castle    = -1
-		err       = errors.New("invalid move")
+		err       = ErrNoSuchMove
 	)
 
 	if len(s) < 2 {
@@ -76,6 +131,10 @@ func (b *Board) ParseMove(s string) (Move, error) {
 				r0, r1 = r1, int(c-'1')
 			}
 		}
+		// At least the file of the destination square must be given.
+		if f1 == -1 {
+			return NullMove, err
+		}
 		// If the piece type is unknown, because it is not specified
 		// and the from-square is unknown, then it must be a pawn (e.g.
 		// e4, cxd5).
@@ -109,26 +168,128 @@ func (b *Board) ParseMove(s string) (Move, error) {
 	move := NullMove
 	moves, _ := b.pseudoLegalMoves()
 	for _, m := range moves {
-		if (piece == NoPiece || b.Piece[m.From].Type() == piece) &&
-			(f0 == -1 || f0 == m.From.File()) &&
-			(r0 == -1 || r0 == m.From.Rank()) &&
-			(f1 == -1 || f1 == m.To.File()) &&
+		if (piece != NoPiece && b.Piece[m.From].Type() != piece) ||
+			(f0 != -1 && f0 != m.From.File()) ||
+			(r0 != -1 && r0 != m.From.Rank()) {
+			continue // the piece does not match
+		}
+		if (f1 == -1 || f1 == m.To.File()) &&
 			(r1 == -1 || r1 == m.To.Rank()) &&
 			m.Promotion.Type() == promotion &&
 			m.isLegal(b) {
 			// the move matches
 			if move != NullMove {
-				return NullMove, err // ambiguous move
+				return NullMove, ErrAmbiguousMove
 			}
 			move = m
 		}
 	}
 	if move == NullMove {
+		// Report a move for a piece the side to move does not have,
+		// typically a move of the wrong color, more clearly.
+		if !b.hasPiece(piece, f0, r0) {
+			name := "piece"
+			if piece != NoPiece {
+				name = pieceNames[piece>>1]
+			}
+			return NullMove, fmt.Errorf("%w: no %s of the side to move can make this move", err, name)
+		}
 		return NullMove, err
 	}
 	return move, nil
 }
 
+// ParseMoveVerify is like ParseMove, but additionally checks that a check
+// ('+') or mate ('#') symbol following the move matches the position after
+// the move, returning ErrCheckMismatch if it does not. A move without such a
+// symbol is not checked, as many sources omit them.
+func (b *Board) ParseMoveVerify(s string) (Move, error) {
+	m, err := b.ParseMove(s)
+	if err != nil {
+		return m, err
+	}
+	var want string
+	switch i := strings.LastIndexAny(s, "+#"); {
+	case i < 0:
+		return m, nil
+	case s[i] == '#':
+		want = "mate"
+	default:
+		want = "check"
+	}
+	got := "no check"
+	switch check, mate := b.MakeMove(m).IsCheckOrMate(); {
+	case check && mate:
+		got = "mate"
+	case check:
+		got = "check"
+	}
+	if got != want {
+		return m, fmt.Errorf("%w: %s gives %s", ErrCheckMismatch, s, got)
+	}
+	return m, nil
+}
+
+var pieceNames = []string{"", "pawn", "knight", "bishop", "rook", "queen", "king"}
+
+// hasPiece reports whether the side to move has a piece of the given type (or
+// any piece if typ is NoPiece) on the given file and rank (or any file or rank
+// if -1).
+func (b *Board) hasPiece(typ, file, rank int) bool {
+	for sq, p := range b.Piece {
+		if p != NoPiece && p.Color() == b.SideToMove &&
+			(typ == NoPiece || p.Type() == typ) &&
+			(file == -1 || file == Sq(sq).File()) &&
+			(rank == -1 || rank == Sq(sq).Rank()) {
+			return true
+		}
+	}
+	return false
+}
+
+// DoMoves plays a list of moves in UCI notation (as in "position startpos
+// moves e2e4 e7e5") from this position, returning the resulting position. If
+// a move cannot be parsed or is illegal, an error identifying the move is
+// returned.
+func (b *Board) DoMoves(uciMoves []string) (*Board, error) {
+	b, _, err := b.playMoves(uciMoves)
+	return b, err
+}
+
+// ApplySAN plays a list of moves in Standard Algebraic Notation (such as "e4",
+// "Nf3", "O-O") from this position. It returns the resulting position and the
+// moves played. If a move cannot be parsed or is illegal, an error identifying
+// the move is returned.
+func (b *Board) ApplySAN(moves []string) (*Board, []Move, error) {
+	return b.playMoves(moves)
+}
+
+// playMoves parses and plays a list of moves, see DoMoves and ApplySAN.
+func (b *Board) playMoves(list []string) (*Board, []Move, error) {
+	moves := make([]Move, 0, len(list))
+	for i, s := range list {
+		m, err := b.ParseMove(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("move %d (%s): %w", i+1, s, err)
+		}
+		moves = append(moves, m)
+		b = b.MakeMove(m)
+	}
+	return b, moves, nil
+}
+
+// stripEnPassant removes a trailing en-passant marker from a move, along with
+// any check and annotation characters around it.
+func stripEnPassant(s string) string {
+	s = strings.TrimRight(s, "+#!? ")
+	for _, suffix := range []string{"e.p.", "e.p", "ep"} {
+		if strings.HasSuffix(s, suffix) {
+			return strings.TrimRight(s[:len(s)-len(suffix)], "+#!? ")
+		}
+	}
+	return s
+}
+
 // Uci returns the move in Universal Chess Interface notation (b1c3, f7f8q).
 // For chess960 compatibility, castling is written as king-takes-own-rook
 // (e1h1) rather than king-moves-two-squares (e1g1).
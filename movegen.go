@@ -8,6 +8,8 @@ type movegen struct {
 }
 
 // LegalMoves returns the list of moves that can be played in this position.
+// The moves are sorted by from-square, then by to-square (both in the order
+// A1, B1, ..., H8) and then by promotion piece, from queen to knight.
 func (b *Board) LegalMoves() []Move {
 	moves, _ := b.pseudoLegalMoves()
 	j := 0
@@ -22,19 +24,114 @@ func (b *Board) LegalMoves() []Move {
 	return moves
 }
 
-// Some ordering on moves to have LegalMoves return moves in a fixed order.
+// LegalMovesFrom returns the legal moves of the piece on sq, in the order of
+// LegalMoves. Castling moves are included when sq holds the king. It returns
+// nil if sq does not hold a piece of the side to move, or if sq is not on the
+// board, such as NoSquare.
+func (b *Board) LegalMovesFrom(sq Sq) []Move {
+	if sq < A1 || sq > H8 {
+		return nil
+	}
+	gen := movegen{Board: b}
+	gen.piece(sq)
+	var moves []Move
+	for _, m := range gen.moves {
+		if m.isLegal(b) {
+			moves = append(moves, m)
+		}
+	}
+	sort.Sort(moveList(moves))
+	return moves
+}
+
+// AttacksFrom returns the squares attacked by the piece on sq, sorted from A1
+// to H8, regardless of whether moving there would be legal. Squares occupied
+// by pieces of either color are included (friendly pieces are defended); a
+// slider's attacks stop at the first piece in each direction. Pawns attack
+// their two diagonal capture squares only. It returns nil if sq is empty.
+func (b *Board) AttacksFrom(sq Sq) []Sq {
+	var (
+		diagonal = []int{-9, -7, 7, 9}
+		straight = []int{-8, -1, 1, 8}
+		offsets  []int
+		slider   bool
+	)
+	switch p := b.Piece[sq]; p.Type() {
+	case Pawn:
+		offsets = [][]int{{7, 9}, {-9, -7}}[p.Color()]
+	case Knight:
+		offsets = []int{-17, -15, -10, -6, 6, 10, 15, 17}
+	case Bishop:
+		offsets, slider = diagonal, true
+	case Rook:
+		offsets, slider = straight, true
+	case Queen:
+		offsets, slider = append(diagonal, straight...), true
+	case King:
+		offsets = append(diagonal, straight...)
+	}
+	var attacks []Sq
+	for _, offset := range offsets {
+		for to := sq.step(offset); to != NoSquare; to = to.step(offset) {
+			attacks = append(attacks, to)
+			if !slider || b.Piece[to] != NoPiece {
+				break
+			}
+		}
+	}
+	sort.Slice(attacks, func(i, j int) bool { return attacks[i] < attacks[j] })
+	return attacks
+}
+
+// attacked returns whether sq is attacked by a piece of the side to move. It
+// looks outward from sq for attackers rather than generating moves, which
+// makes it much cheaper than pseudoLegalMoves.
+func (b *Board) attacked(sq Sq) bool {
+	for _, offset := range [][]int{{-9, -7}, {7, 9}}[b.SideToMove] {
+		if to := sq.step(offset); to != NoSquare && b.Piece[to] == b.my(Pawn) {
+			return true
+		}
+	}
+	for _, offset := range []int{-17, -15, -10, -6, 6, 10, 15, 17} {
+		if to := sq.step(offset); to != NoSquare && b.Piece[to] == b.my(Knight) {
+			return true
+		}
+	}
+	for _, offset := range []int{-9, -8, -7, -1, 1, 7, 8, 9} {
+		if to := sq.step(offset); to != NoSquare && b.Piece[to] == b.my(King) {
+			return true
+		}
+	}
+	for _, offset := range []int{-9, -8, -7, -1, 1, 7, 8, 9} {
+		slider := b.my(Rook)
+		if offset == -9 || offset == -7 || offset == 7 || offset == 9 {
+			slider = b.my(Bishop)
+		}
+		for to := sq.step(offset); to != NoSquare; to = to.step(offset) {
+			if p := b.Piece[to]; p != NoPiece {
+				if p == slider || p == b.my(Queen) {
+					return true
+				}
+				break
+			}
+		}
+	}
+	return false
+}
+
+// moveList sorts moves in the order documented for LegalMoves.
 type moveList []Move
 
 func (l moveList) Len() int      { return len(l) }
 func (l moveList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
 func (l moveList) Less(i, j int) bool {
-	if l[i].From < l[j].From {
-		return true
+	if l[i].From != l[j].From {
+		return l[i].From < l[j].From
 	}
-	if l[i].To < l[j].To {
-		return true
+	if l[i].To != l[j].To {
+		return l[i].To < l[j].To
 	}
-	return l[i].Promotion > l[j].Promotion
+	return l[i].Promotion.Type() > l[j].Promotion.Type()
 }
 
 // pseudoLegalMoves returns the list of "pseudo-legal" moves in the current
@@ -43,26 +140,8 @@ func (l moveList) Less(i, j int) bool {
 // opponent's king is in check.
 func (b *Board) pseudoLegalMoves() (moves []Move, check bool) {
 	gen := movegen{Board: b}
-	for i, piece := range gen.Piece {
-		if piece == NoPiece || piece.Color() != gen.SideToMove {
-			continue
-		}
-		sq := Sq(i)
-		switch piece.Type() {
-		case Pawn:
-			gen.pawn(sq)
-		case Knight:
-			gen.knight(sq)
-		case Bishop:
-			gen.bishop(sq)
-		case Rook:
-			gen.rook(sq)
-		case Queen:
-			gen.bishop(sq)
-			gen.rook(sq)
-		case King:
-			gen.king(sq)
-		}
+	for i := range gen.Piece {
+		gen.piece(Sq(i))
 	}
 	// the position is illegal if the opponent is in check
 	checkFrom, checkTo := gen.checkFrom, gen.checkTo
@@ -78,6 +157,30 @@ func (b *Board) pseudoLegalMoves() (moves []Move, check bool) {
 	return gen.moves, false
 }
 
+// piece generates the pseudo-legal moves of the piece on sq, if it belongs to
+// the side to move.
+func (gen *movegen) piece(sq Sq) {
+	piece := gen.Piece[sq]
+	if piece == NoPiece || piece.Color() != gen.SideToMove {
+		return
+	}
+	switch piece.Type() {
+	case Pawn:
+		gen.pawn(sq)
+	case Knight:
+		gen.knight(sq)
+	case Bishop:
+		gen.bishop(sq)
+	case Rook:
+		gen.rook(sq)
+	case Queen:
+		gen.bishop(sq)
+		gen.rook(sq)
+	case King:
+		gen.king(sq)
+	}
+}
+
 // step returns the square reached by a piece stepping the given offset. It
 // returns NoSquare if the piece would fall off the board. The offset must not
 // jump more than two files (a knight's jump) because jumps >2 files are used
@@ -212,9 +315,22 @@ func (b *Board) castleSquares(wing int) (rf, kf, rt, kt, min, max Sq) {
 	return
 }
 
+// CastlePath returns the squares the king and rook of the side to move move
+// from and to when castling on the given wing (WhiteOO or WhiteOOO; the color
+// is ignored). This also works for chess960 positions. It returns ok=false if
+// the side to move has no right to castle on that wing. Note that the castling
+// move need not be legal: the path may be blocked or the king may be in check.
+func (b *Board) CastlePath(wing int) (kingFrom, kingTo, rookFrom, rookTo Sq, ok bool) {
+	rf, kf, rt, kt, _, _ := b.castleSquares(wing &^ 0x01)
+	if rf == NoSquare || kf == NoSquare {
+		return NoSquare, NoSquare, NoSquare, NoSquare, false
+	}
+	return kf, kt, rf, rt, true
+}
+
 // canCastle returns whether the side to move can castle on the given wing.
 // Note: this does not check whether the king moves through an attacked square;
-// use move.isLegal() for that.
+// use move.isLegal() or CanCastleLegally for that.
 func (b *Board) canCastle(wing int) bool {
 	rf, kf, _, _, min, max := b.castleSquares(wing)
 	if rf == NoSquare {
@@ -229,6 +345,19 @@ func (b *Board) canCastle(wing int) bool {
 	return true
 }
 
+// CanCastleLegally returns whether the side to move can castle on the given
+// wing (WhiteOO or WhiteOOO; the color is ignored): it has the castling right,
+// the squares between king and rook and their destinations are empty, the king
+// is not in check and does not move through or into an attacked square.
+func (b *Board) CanCastleLegally(wing int) bool {
+	wing &^= 0x01
+	if !b.canCastle(wing) {
+		return false
+	}
+	rf, kf, _, _, _, _ := b.castleSquares(wing)
+	return Move{From: kf, To: rf}.isLegal(b)
+}
+
 // IsCheckOrMate returns whether the side to move is in check and/or has been
 // mated. Mate without check means stalemate.
 func (b *Board) IsCheckOrMate() (check, mate bool) {
@@ -244,3 +373,80 @@ func (b *Board) IsCheckOrMate() (check, mate bool) {
 	mate = true // no moves: mate or stalemate
 	return
 }
+
+// IsCheck returns whether the side to move is in check.
+func (b *Board) IsCheck() bool {
+	_, check := b.MakeMove(NullMove).pseudoLegalMoves()
+	return check
+}
+
+// IsCheckmate returns whether the side to move has been checkmated.
+func (b *Board) IsCheckmate() bool {
+	check, mate := b.IsCheckOrMate()
+	return check && mate
+}
+
+// IsStalemate returns whether the side to move is stalemated: it is not in
+// check but has no legal moves.
+func (b *Board) IsStalemate() bool {
+	check, mate := b.IsCheckOrMate()
+	return !check && mate
+}
+
+// Checkers returns the squares of the opponent's pieces that give check to
+// the side to move, sorted from A1 to H8. It returns nil if the side to move
+// is not in check.
+func (b *Board) Checkers() []Sq {
+	king := b.find(b.my(King), A1, H8)
+	if king == NoSquare {
+		return nil
+	}
+	var checkers []Sq
+	for sq, p := range b.Piece {
+		if p == NoPiece || p.Color() == b.SideToMove {
+			continue
+		}
+		for _, to := range b.AttacksFrom(Sq(sq)) {
+			if to == king {
+				checkers = append(checkers, Sq(sq))
+				break
+			}
+		}
+	}
+	return checkers
+}
+
+// PinnedPieces returns the squares of the pieces of the side to move that are
+// pinned to their king: they stand between the king and an opponent's bishop,
+// rook or queen attacking along that line, and are the only piece in between.
+// The squares are sorted from A1 to H8.
+func (b *Board) PinnedPieces() []Sq {
+	king := b.find(b.my(King), A1, H8)
+	if king == NoSquare {
+		return nil
+	}
+	var pinned []Sq
+	for _, offset := range []int{-9, -8, -7, -1, 1, 7, 8, 9} {
+		pinner := b.opp(Rook)
+		if offset == -9 || offset == -7 || offset == 7 || offset == 9 {
+			pinner = b.opp(Bishop)
+		}
+		candidate := NoSquare
+		for sq := king.step(offset); sq != NoSquare; sq = sq.step(offset) {
+			p := b.Piece[sq]
+			if p == NoPiece {
+				continue
+			}
+			if candidate == NoSquare && p.Color() == b.SideToMove {
+				candidate = sq
+				continue
+			}
+			if candidate != NoSquare && (p == pinner || p == b.opp(Queen)) {
+				pinned = append(pinned, candidate)
+			}
+			break
+		}
+	}
+	sort.Slice(pinned, func(i, j int) bool { return pinned[i] < pinned[j] })
+	return pinned
+}
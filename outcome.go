@@ -0,0 +1,60 @@
+package chess
+
+// FiftyMoveDraw returns whether a draw can be claimed under the fifty-move
+// rule: no pawn has moved and no piece has been captured in the last 50 moves
+// (100 halfmoves).
+func (b *Board) FiftyMoveDraw() bool {
+	return b.Rule50 >= 100
+}
+
+// SeventyFiveMoveDraw returns whether the game is drawn under the FIDE
+// seventy-five-move rule: no pawn has moved and no piece has been captured in
+// the last 75 moves (150 halfmoves). Unlike the fifty-move rule, this draw
+// does not need to be claimed.
+func (b *Board) SeventyFiveMoveDraw() bool {
+	return b.Rule50 >= 150
+}
+
+// InsufficientMaterial returns whether neither side has enough material left to
+// checkmate: there are no pawns, rooks or queens on the board and either at
+// most one knight or bishop, or only bishops that all move on squares of the
+// same color.
+func (b *Board) InsufficientMaterial() bool {
+	minors := 0
+	var bishopColors [2]int // number of bishops on dark and light squares
+	for sq, p := range b.Piece {
+		switch p.Type() {
+		case Pawn, Rook, Queen:
+			return false
+		case Knight:
+			minors++
+		case Bishop:
+			minors++
+			bishopColors[(Sq(sq).File()+Sq(sq).Rank())%2]++
+		}
+	}
+	if minors <= 1 {
+		return true
+	}
+	return minors == bishopColors[0] || minors == bishopColors[1]
+}
+
+// Outcome returns whether the game is finished in this position, and if so
+// the result ("1-0", "0-1" or "1/2-1/2"). If the game is not finished the
+// result is "". The game is finished after checkmate, stalemate, when neither
+// side can mate (InsufficientMaterial) or under the fifty-move rule, which
+// includes the seventy-five-move rule. Threefold repetition cannot be told
+// from a single board: use History.GameOver to include it.
+func (b *Board) Outcome() (finished bool, result string) {
+	check, mate := b.IsCheckOrMate()
+	switch {
+	case mate && check:
+		if b.SideToMove == White {
+			return true, "0-1"
+		}
+		return true, "1-0"
+	case mate, b.InsufficientMaterial(), b.FiftyMoveDraw():
+		return true, "1/2-1/2"
+	}
+	return false, ""
+}
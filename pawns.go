@@ -0,0 +1,57 @@
+package chess
+
+// Pawn structure. The functions below return false if there is no pawn on the
+// given square.
+
+// IsPassedPawn returns whether the pawn on sq is a passed pawn: there are no
+// enemy pawns in front of it on its own file or the adjacent files.
+func (b *Board) IsPassedPawn(sq Sq) bool {
+	p := b.Piece[sq]
+	if p.Type() != Pawn {
+		return false
+	}
+	color := p.Color()
+	return !b.anyPawn(Piece(color^1|Pawn), sq.File()-1, sq.File()+1, func(s Sq) bool {
+		return s.RelativeRank(color) > sq.RelativeRank(color)
+	})
+}
+
+// IsIsolated returns whether the pawn on sq is isolated: there are no friendly
+// pawns on the adjacent files.
+func (b *Board) IsIsolated(sq Sq) bool {
+	p := b.Piece[sq]
+	if p.Type() != Pawn {
+		return false
+	}
+	return !b.anyPawn(p, sq.File()-1, sq.File()+1, func(s Sq) bool {
+		return s.File() != sq.File()
+	})
+}
+
+// IsDoubled returns whether the pawn on sq is doubled: there is another
+// friendly pawn on the same file.
+func (b *Board) IsDoubled(sq Sq) bool {
+	p := b.Piece[sq]
+	if p.Type() != Pawn {
+		return false
+	}
+	return b.anyPawn(p, sq.File(), sq.File(), func(s Sq) bool {
+		return s != sq
+	})
+}
+
+// anyPawn returns whether there is a pawn p on the files file0 to file1 (which
+// are clipped to the board) on a square for which match returns true.
+func (b *Board) anyPawn(p Piece, file0, file1 int, match func(Sq) bool) bool {
+	for file := file0; file <= file1; file++ {
+		if file < 0 || file > 7 {
+			continue
+		}
+		for rank := 0; rank < 8; rank++ {
+			if s := Square(file, rank); b.Piece[s] == p && match(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package pgn
+
+import (
+	"fmt"
+	"github.com/malbrecht/chess"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Embedded commands are annotations of the form [%name value] inside
+// comments, used by many programs and servers to store structured data such as
+// clock times with the moves.
+
+// findCommand locates the embedded command [%name ...] in s. It returns the
+// start and end of the command (including the brackets) and its value, or
+// i=-1 if s does not contain the command.
+func findCommand(s, name string) (i, j int, value string) {
+	prefix := "[%" + name
+	for off := 0; ; {
+		k := strings.Index(s[off:], prefix)
+		if k < 0 {
+			return -1, -1, ""
+		}
+		i = off + k
+		off = i + len(prefix)
+		rest := s[off:]
+		if rest == "" || (rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' && rest[0] != ']') {
+			continue // a command with a longer name
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return -1, -1, ""
+		}
+		return i, off + end + 1, strings.TrimSpace(rest[:end])
+	}
+}
+
+// command returns the value of the embedded command [%name value] in the
+// node's comments. It returns !ok if there is no such command.
+func (n *Node) command(name string) (value string, ok bool) {
+	for _, c := range n.Comment {
+		if i, _, v := findCommand(c, name); i >= 0 {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// setCommand sets the value of the embedded command [%name value] in the
+// node's comments, replacing a previous value if there is one.
+func (n *Node) setCommand(name, value string) {
+	cmd := fmt.Sprintf("[%%%s %s]", name, value)
+	for k, c := range n.Comment {
+		if i, j, _ := findCommand(c, name); i >= 0 {
+			n.Comment[k] = c[:i] + cmd + c[j:]
+			return
+		}
+	}
+	if len(n.Comment) == 0 {
+		n.Comment = append(n.Comment, cmd)
+		return
+	}
+	last := len(n.Comment) - 1
+	n.Comment[last] = strings.TrimSpace(n.Comment[last] + " " + cmd)
+}
+
+// Clock returns the clock time embedded as [%clk H:MM:SS] in the node's
+// comments. It returns !ok if there is no (valid) clock time.
+func (n *Node) Clock() (time.Duration, bool) {
+	v, ok := n.command("clk")
+	if !ok {
+		return 0, false
+	}
+	return parseClock(v)
+}
+
+// SetClock embeds the clock time d in the node's comments as [%clk H:MM:SS].
+func (n *Node) SetClock(d time.Duration) {
+	n.setCommand("clk", formatClock(d))
+}
+
+// ElapsedMoveTime returns the time spent on the move, embedded as
+// [%emt H:MM:SS] in the node's comments. It returns !ok if there is no (valid)
+// elapsed move time.
+func (n *Node) ElapsedMoveTime() (time.Duration, bool) {
+	v, ok := n.command("emt")
+	if !ok {
+		return 0, false
+	}
+	return parseClock(v)
+}
+
+// SetElapsedMoveTime embeds the time spent on the move in the node's comments
+// as [%emt H:MM:SS].
+func (n *Node) SetElapsedMoveTime(d time.Duration) {
+	n.setCommand("emt", formatClock(d))
+}
+
+// parseClock parses a time in H:MM:SS format, where the seconds can have a
+// fractional part.
+func parseClock(s string) (time.Duration, bool) {
+	hms := strings.Split(s, ":")
+	if len(hms) != 3 {
+		return 0, false
+	}
+	h, err := strconv.Atoi(hms[0])
+	if err != nil || h < 0 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(hms[1])
+	if err != nil || m < 0 || m >= 60 {
+		return 0, false
+	}
+	sec, err := strconv.ParseFloat(hms[2], 64)
+	if err != nil || sec < 0 || sec >= 60 {
+		return 0, false
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(sec*float64(time.Second))
+	return d.Round(time.Millisecond), true
+}
+
+// formatClock formats d as H:MM:SS, adding a fractional part to the seconds
+// if d is not a whole number of seconds.
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Millisecond)
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	s := d % time.Minute / time.Second
+	clock := fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	if ms := d % time.Second / time.Millisecond; ms != 0 {
+		clock += strings.TrimRight(fmt.Sprintf(".%03d", ms), "0")
+	}
+	return clock
+}
+
+// Eval returns the engine evaluation embedded as [%eval score] in the node's
+// comments. The score is either in pawns (e.g. 0.34, returned as 34
+// centipawns) or a mate distance (e.g. #-4, returned as mate=-4). Positive
+// scores are good for White. It returns !ok if there is no (valid) evaluation.
+func (n *Node) Eval() (cp int, mate int, ok bool) {
+	v, ok := n.command("eval")
+	if !ok {
+		return 0, 0, false
+	}
+	// ignore the search depth that some programs add (0.34,20)
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	if strings.HasPrefix(v, "#") {
+		mate, err := strconv.Atoi(v[1:])
+		if err != nil || mate == 0 {
+			return 0, 0, false
+		}
+		return 0, mate, true
+	}
+	pawns, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(pawns) || math.IsInf(pawns, 0) {
+		return 0, 0, false
+	}
+	return int(math.Round(pawns * 100)), 0, true
+}
+
+// SetEval embeds an engine evaluation in the node's comments as [%eval score].
+// If mate is non-zero, the score is written as a mate distance, otherwise cp
+// is written in pawns.
+func (n *Node) SetEval(cp, mate int) {
+	if mate != 0 {
+		n.setCommand("eval", fmt.Sprintf("#%d", mate))
+	} else {
+		n.setCommand("eval", fmt.Sprintf("%.2f", float64(cp)/100))
+	}
+}
+
+// Arrow is an arrow drawn on the board, as embedded in comments by
+// [%cal Gd2d4,Re1e8]. Color is the color letter: 'G'reen, 'R'ed, 'Y'ellow or
+// 'B'lue.
+type Arrow struct {
+	From, To chess.Sq
+	Color    rune
+}
+
+// SquareHighlight is a colored square, as embedded in comments by
+// [%csl Ye4,Rd5]. Color is the color letter, like for Arrow.
+type SquareHighlight struct {
+	Sq    chess.Sq
+	Color rune
+}
+
+// Arrows returns the arrows embedded as [%cal ...] in the node's comments.
+// Invalid entries are skipped.
+func (n *Node) Arrows() []Arrow {
+	var arrows []Arrow
+	for _, v := range n.commands("cal") {
+		for _, a := range strings.Split(v, ",") {
+			a = strings.TrimSpace(a)
+			if len(a) != 5 {
+				continue
+			}
+			from, ok1 := parseSquare(a[1:3])
+			to, ok2 := parseSquare(a[3:5])
+			if ok1 && ok2 {
+				arrows = append(arrows, Arrow{from, to, rune(a[0])})
+			}
+		}
+	}
+	return arrows
+}
+
+// Squares returns the highlighted squares embedded as [%csl ...] in the node's
+// comments. Invalid entries are skipped.
+func (n *Node) Squares() []SquareHighlight {
+	var squares []SquareHighlight
+	for _, v := range n.commands("csl") {
+		for _, h := range strings.Split(v, ",") {
+			h = strings.TrimSpace(h)
+			if len(h) != 3 {
+				continue
+			}
+			if sq, ok := parseSquare(h[1:]); ok {
+				squares = append(squares, SquareHighlight{sq, rune(h[0])})
+			}
+		}
+	}
+	return squares
+}
+
+// commands returns the values of all embedded commands [%name value] in the
+// node's comments.
+func (n *Node) commands(name string) []string {
+	var values []string
+	for _, c := range n.Comment {
+		for {
+			_, j, v := findCommand(c, name)
+			if j < 0 {
+				break
+			}
+			values = append(values, v)
+			c = c[j:]
+		}
+	}
+	return values
+}
+
+// parseSquare parses a square name such as "e4".
+func parseSquare(s string) (chess.Sq, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return chess.NoSquare, false
+	}
+	return chess.Square(int(s[0]-'a'), int(s[1]-'1')), true
+}
@@ -0,0 +1,135 @@
+package pgn
+
+import (
+	"github.com/malbrecht/chess"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type clockTest struct {
+	comment string
+	clock   time.Duration
+	ok      bool
+}
+
+var clockTests = []clockTest{
+	{"[%clk 0:05:23]", 5*time.Minute + 23*time.Second, true},
+	{"good move [%clk 1:02:03.4] [%eval 0.3]", time.Hour + 2*time.Minute + 3400*time.Millisecond, true},
+	{"no clock here", 0, false},
+	{"[%clk 0:5]", 0, false},
+	{"[%clk 0:05:xx]", 0, false},
+	{"[%clkx 0:05:23]", 0, false},
+}
+
+func TestClock(t *testing.T) {
+	for _, test := range clockTests {
+		n := &Node{Comment: []string{test.comment}}
+		clock, ok := n.Clock()
+		if clock != test.clock || ok != test.ok {
+			t.Errorf("%q: got %v, %v, want %v, %v", test.comment, clock, ok, test.clock, test.ok)
+		}
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	n := &Node{}
+	n.SetClock(5*time.Minute + 23*time.Second)
+	if c := n.Comment; len(c) != 1 || c[0] != "[%clk 0:05:23]" {
+		t.Errorf("got comment %q", c)
+	}
+	n = &Node{Comment: []string{"first", "good move [%clk 1:00:00] [%eval 0.3]"}}
+	n.SetClock(time.Hour + 2*time.Minute + 3400*time.Millisecond)
+	if c := n.Comment; len(c) != 2 || c[1] != "good move [%clk 1:02:03.4] [%eval 0.3]" {
+		t.Errorf("got comment %q", c)
+	}
+	n = &Node{Comment: []string{"good move"}}
+	n.SetClock(10 * time.Second)
+	if c := n.Comment; len(c) != 1 || c[0] != "good move [%clk 0:00:10]" {
+		t.Errorf("got comment %q", c)
+	}
+}
+
+func TestElapsedMoveTime(t *testing.T) {
+	n := &Node{Comment: []string{"[%clk 0:04:48] [%emt 0:00:12]"}}
+	if emt, ok := n.ElapsedMoveTime(); emt != 12*time.Second || !ok {
+		t.Errorf("got %v, %v, want %v, true", emt, ok, 12*time.Second)
+	}
+	if clock, ok := n.Clock(); clock != 4*time.Minute+48*time.Second || !ok {
+		t.Errorf("got clock %v, %v", clock, ok)
+	}
+
+	n = &Node{Comment: []string{"good move"}}
+	if _, ok := n.ElapsedMoveTime(); ok {
+		t.Error("got elapsed move time for comment without emt command")
+	}
+	d := time.Minute + 2500*time.Millisecond
+	n.SetElapsedMoveTime(d)
+	if c := n.Comment; len(c) != 1 || c[0] != "good move [%emt 0:01:02.5]" {
+		t.Errorf("got comment %q", c)
+	}
+	if emt, ok := n.ElapsedMoveTime(); emt != d || !ok {
+		t.Errorf("got %v, %v after SetElapsedMoveTime(%v)", emt, ok, d)
+	}
+}
+
+type evalTest struct {
+	comment  string
+	cp, mate int
+	ok       bool
+}
+
+var evalTests = []evalTest{
+	{"[%eval 0.34]", 34, 0, true},
+	{"[%eval -1.5]", -150, 0, true},
+	{"[%eval #-4]", 0, -4, true},
+	{"[%clk 0:01:00] [%eval 0.17,20]", 17, 0, true},
+	{"[%eval #]", 0, 0, false},
+	{"[%eval abc]", 0, 0, false},
+	{"[%eval NaN]", 0, 0, false},
+	{"[%eval +Inf]", 0, 0, false},
+	{"[%eval -inf]", 0, 0, false},
+	{"no eval here", 0, 0, false},
+}
+
+func TestEval(t *testing.T) {
+	for _, test := range evalTests {
+		n := &Node{Comment: []string{test.comment}}
+		cp, mate, ok := n.Eval()
+		if cp != test.cp || mate != test.mate || ok != test.ok {
+			t.Errorf("%q: got %d, %d, %v, want %d, %d, %v", test.comment,
+				cp, mate, ok, test.cp, test.mate, test.ok)
+		}
+	}
+}
+
+func TestSetEval(t *testing.T) {
+	n := &Node{}
+	n.SetEval(-150, 0)
+	if c := n.Comment; len(c) != 1 || c[0] != "[%eval -1.50]" {
+		t.Errorf("got comment %q", c)
+	}
+	n.SetEval(0, 3)
+	if c := n.Comment; len(c) != 1 || c[0] != "[%eval #3]" {
+		t.Errorf("got comment %q", c)
+	}
+}
+
+func TestArrowsSquares(t *testing.T) {
+	n := &Node{Comment: []string{"the plan [%cal Gd2d4,Re1e8] [%csl Ye4]"}}
+	arrows := []Arrow{{chess.D2, chess.D4, 'G'}, {chess.E1, chess.E8, 'R'}}
+	if got := n.Arrows(); !reflect.DeepEqual(got, arrows) {
+		t.Errorf("got arrows %v, want %v", got, arrows)
+	}
+	squares := []SquareHighlight{{chess.E4, 'Y'}}
+	if got := n.Squares(); !reflect.DeepEqual(got, squares) {
+		t.Errorf("got squares %v, want %v", got, squares)
+	}
+	n = &Node{Comment: []string{"no drawings [%cal Gd2] [%csl]"}}
+	if got := n.Arrows(); got != nil {
+		t.Errorf("got arrows %v, want none", got)
+	}
+	if got := n.Squares(); got != nil {
+		t.Errorf("got squares %v, want none", got)
+	}
+}
@@ -0,0 +1,69 @@
+// Package eco classifies chess games by their opening, using the codes of the
+// Encyclopaedia of Chess Openings (ECO).
+package eco
+
+import (
+	"github.com/malbrecht/chess"
+	"github.com/malbrecht/chess/pgn"
+	"strings"
+	"sync"
+)
+
+// Opening is a classified opening.
+type Opening struct {
+	Code  string // ECO code, e.g. "B90"
+	Name  string // opening name, e.g. "Sicilian, Najdorf"
+	Moves string // moves leading to the opening, in SAN
+}
+
+// openings maps the position hash of each opening in the table to the
+// opening. It is filled in on first use.
+var (
+	openings     map[uint64]*Opening
+	openingsOnce sync.Once
+)
+
+// load plays through the moves of the openings in the table.
+func load() {
+	openings = make(map[uint64]*Opening, len(table))
+	for i := range table {
+		o := &table[i]
+		b := chess.MustParseFen("")
+		for _, san := range strings.Fields(o.Moves) {
+			m, err := b.ParseMove(san)
+			if err != nil {
+				panic("eco: " + o.Code + " " + o.Moves + ": " + san + ": " + err.Error())
+			}
+			b = b.MakeMove(m)
+		}
+		openings[b.Hash()] = o
+	}
+}
+
+// Lookup returns the opening that the position belongs to, or nil if the
+// position is not in the table.
+func Lookup(b *chess.Board) *Opening {
+	openingsOnce.Do(load)
+	return openings[b.Hash()]
+}
+
+// Classify returns the ECO code and name of the opening of game g. It walks
+// the main line and returns the last position found in the table, so that the
+// most specific opening is returned. Positions are compared rather than move
+// sequences, so transpositions are recognized. Empty strings are returned if
+// the opening is unknown or the movetext of the game cannot be parsed.
+func Classify(g *pgn.Game) (code, name string) {
+	if err := g.ParseMoves(); err != nil {
+		return "", ""
+	}
+	var found *Opening
+	for _, n := range g.MainLine() {
+		if o := Lookup(n.Board); o != nil {
+			found = o
+		}
+	}
+	if found == nil {
+		return "", ""
+	}
+	return found.Code, found.Name
+}
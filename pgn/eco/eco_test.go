@@ -0,0 +1,44 @@
+package eco
+
+import (
+	"github.com/malbrecht/chess/pgn"
+	"testing"
+)
+
+type classifyTest struct {
+	movetext string
+	code     string
+	name     string
+}
+
+var classifyTests = []classifyTest{
+	{"1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6",
+		"C88", "Ruy Lopez, Closed"},
+	{"1. e4 c5 2. Nf3 d6 3. d4 cxd4 4. Nxd4 Nf6 5. Nc3 a6 6. Be3 e5",
+		"B90", "Sicilian, Najdorf"},
+	// transposition: 1. d4 Nf6 2. c4 e6 3. Nc3 d5 is the QGD
+	{"1. d4 Nf6 2. c4 e6 3. Nc3 d5", "D35", "Queen's Gambit Declined"},
+	{"1. h4 h5", "", ""},
+}
+
+func TestClassify(t *testing.T) {
+	for _, test := range classifyTests {
+		var db pgn.DB
+		if errs := db.Parse(`[Result "*"] ` + test.movetext); errs != nil {
+			t.Fatal(errs)
+		}
+		code, name := Classify(db.Games[0])
+		if code != test.code || name != test.name {
+			t.Errorf("%s: got %s %q, want %s %q", test.movetext, code, name,
+				test.code, test.name)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	load() // panics on invalid moves
+	if len(openings) != len(table) {
+		t.Errorf("%d positions for %d openings: duplicates in the table",
+			len(openings), len(table))
+	}
+}
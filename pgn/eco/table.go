@@ -0,0 +1,96 @@
+package eco
+
+// table is a compact selection of the ECO classification, covering the main
+// openings and their most popular variations.
+var table = []Opening{
+	{"A00", "Polish (Sokolsky) Opening", "b4"},
+	{"A01", "Nimzovich-Larsen Attack", "b3"},
+	{"A02", "Bird's Opening", "f4"},
+	{"A04", "Reti Opening", "Nf3"},
+	{"A06", "Reti Opening", "Nf3 d5"},
+	{"A07", "King's Indian Attack", "Nf3 d5 g3"},
+	{"A10", "English Opening", "c4"},
+	{"A20", "English Opening", "c4 e5"},
+	{"A30", "English, Symmetrical", "c4 c5"},
+	{"A40", "Queen's Pawn Game", "d4"},
+	{"A45", "Queen's Pawn Game", "d4 Nf6"},
+	{"A46", "Queen's Pawn Game", "d4 Nf6 Nf3"},
+	{"A48", "King's Indian, East Indian Defence", "d4 Nf6 Nf3 g6"},
+	{"A50", "Queen's Pawn Game", "d4 Nf6 c4"},
+	{"A51", "Budapest Gambit", "d4 Nf6 c4 e5"},
+	{"A56", "Benoni Defence", "d4 Nf6 c4 c5"},
+	{"A57", "Benko Gambit", "d4 Nf6 c4 c5 d5 b5"},
+	{"A80", "Dutch Defence", "d4 f5"},
+	{"B00", "King's Pawn Opening", "e4"},
+	{"B01", "Scandinavian Defence", "e4 d5"},
+	{"B02", "Alekhine's Defence", "e4 Nf6"},
+	{"B06", "Modern Defence", "e4 g6"},
+	{"B07", "Pirc Defence", "e4 d6 d4 Nf6"},
+	{"B10", "Caro-Kann Defence", "e4 c6"},
+	{"B12", "Caro-Kann, Advance Variation", "e4 c6 d4 d5 e5"},
+	{"B13", "Caro-Kann, Exchange Variation", "e4 c6 d4 d5 exd5 cxd5"},
+	{"B15", "Caro-Kann Defence", "e4 c6 d4 d5 Nc3"},
+	{"B20", "Sicilian Defence", "e4 c5"},
+	{"B22", "Sicilian, Alapin Variation", "e4 c5 c3"},
+	{"B23", "Sicilian, Closed", "e4 c5 Nc3"},
+	{"B27", "Sicilian Defence", "e4 c5 Nf3"},
+	{"B30", "Sicilian Defence", "e4 c5 Nf3 Nc6"},
+	{"B33", "Sicilian, Sveshnikov", "e4 c5 Nf3 Nc6 d4 cxd4 Nxd4 Nf6 Nc3 e5"},
+	{"B40", "Sicilian Defence", "e4 c5 Nf3 e6"},
+	{"B50", "Sicilian Defence", "e4 c5 Nf3 d6"},
+	{"B54", "Sicilian Defence", "e4 c5 Nf3 d6 d4 cxd4 Nxd4"},
+	{"B56", "Sicilian Defence", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3"},
+	{"B70", "Sicilian, Dragon Variation", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 g6"},
+	{"B80", "Sicilian, Scheveningen", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 e6"},
+	{"B90", "Sicilian, Najdorf", "e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6 Nc3 a6"},
+	{"C00", "French Defence", "e4 e6"},
+	{"C01", "French, Exchange Variation", "e4 e6 d4 d5 exd5 exd5"},
+	{"C02", "French, Advance Variation", "e4 e6 d4 d5 e5"},
+	{"C03", "French, Tarrasch", "e4 e6 d4 d5 Nd2"},
+	{"C10", "French Defence", "e4 e6 d4 d5 Nc3"},
+	{"C11", "French, Classical", "e4 e6 d4 d5 Nc3 Nf6"},
+	{"C15", "French, Winawer", "e4 e6 d4 d5 Nc3 Bb4"},
+	{"C20", "King's Pawn Game", "e4 e5"},
+	{"C23", "Bishop's Opening", "e4 e5 Bc4"},
+	{"C25", "Vienna Game", "e4 e5 Nc3"},
+	{"C30", "King's Gambit", "e4 e5 f4"},
+	{"C33", "King's Gambit Accepted", "e4 e5 f4 exf4"},
+	{"C40", "King's Knight Opening", "e4 e5 Nf3"},
+	{"C41", "Philidor Defence", "e4 e5 Nf3 d6"},
+	{"C42", "Petrov's Defence", "e4 e5 Nf3 Nf6"},
+	{"C44", "King's Pawn Game", "e4 e5 Nf3 Nc6"},
+	{"C45", "Scotch Game", "e4 e5 Nf3 Nc6 d4 exd4 Nxd4"},
+	{"C46", "Three Knights Game", "e4 e5 Nf3 Nc6 Nc3"},
+	{"C47", "Four Knights Game", "e4 e5 Nf3 Nc6 Nc3 Nf6"},
+	{"C50", "Italian Game", "e4 e5 Nf3 Nc6 Bc4"},
+	{"C51", "Evans Gambit", "e4 e5 Nf3 Nc6 Bc4 Bc5 b4"},
+	{"C53", "Giuoco Piano", "e4 e5 Nf3 Nc6 Bc4 Bc5 c3"},
+	{"C55", "Two Knights Defence", "e4 e5 Nf3 Nc6 Bc4 Nf6"},
+	{"C60", "Ruy Lopez", "e4 e5 Nf3 Nc6 Bb5"},
+	{"C65", "Ruy Lopez, Berlin Defence", "e4 e5 Nf3 Nc6 Bb5 Nf6"},
+	{"C68", "Ruy Lopez, Exchange Variation", "e4 e5 Nf3 Nc6 Bb5 a6 Bxc6"},
+	{"C70", "Ruy Lopez", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4"},
+	{"C78", "Ruy Lopez", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O"},
+	{"C80", "Ruy Lopez, Open", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Nxe4"},
+	{"C84", "Ruy Lopez, Closed", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7"},
+	{"C88", "Ruy Lopez, Closed", "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7 Re1 b5 Bb3"},
+	{"D00", "Queen's Pawn Game", "d4 d5"},
+	{"D02", "Queen's Pawn Game", "d4 d5 Nf3"},
+	{"D06", "Queen's Gambit", "d4 d5 c4"},
+	{"D07", "Queen's Gambit Declined, Chigorin Defence", "d4 d5 c4 Nc6"},
+	{"D10", "Slav Defence", "d4 d5 c4 c6"},
+	{"D20", "Queen's Gambit Accepted", "d4 d5 c4 dxc4"},
+	{"D30", "Queen's Gambit Declined", "d4 d5 c4 e6"},
+	{"D35", "Queen's Gambit Declined", "d4 d5 c4 e6 Nc3 Nf6"},
+	{"D43", "Semi-Slav Defence", "d4 d5 c4 c6 Nf3 Nf6 Nc3 e6"},
+	{"D80", "Gruenfeld Defence", "d4 Nf6 c4 g6 Nc3 d5"},
+	{"E00", "Queen's Pawn Game", "d4 Nf6 c4 e6"},
+	{"E01", "Catalan", "d4 Nf6 c4 e6 g3"},
+	{"E10", "Queen's Pawn Game", "d4 Nf6 c4 e6 Nf3"},
+	{"E12", "Queen's Indian Defence", "d4 Nf6 c4 e6 Nf3 b6"},
+	{"E20", "Nimzo-Indian Defence", "d4 Nf6 c4 e6 Nc3 Bb4"},
+	{"E60", "King's Indian Defence", "d4 Nf6 c4 g6"},
+	{"E61", "King's Indian Defence", "d4 Nf6 c4 g6 Nc3 Bg7"},
+	{"E70", "King's Indian Defence", "d4 Nf6 c4 g6 Nc3 Bg7 e4"},
+	{"E90", "King's Indian Defence", "d4 Nf6 c4 g6 Nc3 Bg7 e4 d6 Nf3"},
+}
@@ -0,0 +1,51 @@
+package pgn
+
+import "strings"
+
+// Filter returns the games in the database for which pred returns true.
+func (d *DB) Filter(pred func(*Game) bool) []*Game {
+	var games []*Game
+	for _, g := range d.Games {
+		if pred(g) {
+			games = append(games, g)
+		}
+	}
+	return games
+}
+
+// ByTag returns a Filter predicate matching games of which the tag key has the
+// given value.
+func ByTag(key, value string) func(*Game) bool {
+	return func(g *Game) bool {
+		v, ok := g.Tags[key]
+		return ok && v == value
+	}
+}
+
+// ByPlayer returns a Filter predicate matching games in which name played
+// either White or Black. The name matches if it is contained in the White or
+// Black tag, ignoring case, so that "carlsen" matches "Carlsen, Magnus".
+func ByPlayer(name string) func(*Game) bool {
+	return func(g *Game) bool {
+		return playerMatches(g.Tags["White"], name) ||
+			playerMatches(g.Tags["Black"], name)
+	}
+}
+
+// ByWinner returns a Filter predicate matching games won by name, where name
+// matches as in ByPlayer.
+func ByWinner(name string) func(*Game) bool {
+	return func(g *Game) bool {
+		switch g.Tags["Result"] {
+		case "1-0":
+			return playerMatches(g.Tags["White"], name)
+		case "0-1":
+			return playerMatches(g.Tags["Black"], name)
+		}
+		return false
+	}
+}
+
+func playerMatches(player, name string) bool {
+	return name != "" && strings.Contains(strings.ToLower(player), strings.ToLower(name))
+}
@@ -158,6 +158,10 @@ loop:
 	l.ignore()
 }
 
+// figurines are the piece symbols that may appear in moves, as written with
+// ExportOptions.Figurines.
+const figurines = "♔♕♖♗♘♙♚♛♜♝♞♟"
+
 // item returns the next item from the input.
 func (l *lexer) item() item {
 	l.emitted = item{}
@@ -168,7 +172,14 @@ func (l *lexer) item() item {
 		case ' ', '\t', '\v', '\r', '\n':
 			l.acceptRun(" \t\v\r\n")
 			l.ignore()
-		case ';', '%':
+		case ';':
+			l.find("\n")
+			l.ignore()
+		case '%':
+			// an escape, which is only recognised at the start of a line
+			if l.start > 0 && l.input[l.start-1] != '\n' {
+				l.panicf("unexpected character: %#U", r)
+			}
 			l.find("\n")
 			l.ignore()
 		case '[':
@@ -202,17 +213,43 @@ func (l *lexer) item() item {
 		case '.':
 			l.acceptRun(".")
 			l.emit(itemDots)
+		case '-':
+			// null move
+			if l.next() != '-' {
+				l.panicf("unexpected character: %#U", r)
+			}
+			l.emit(itemSymbol)
 		default:
-			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') &&
+				!strings.ContainsRune(figurines, r) {
 				l.panicf("unexpected character: %#U", r)
 			}
-			l.acceptRun("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+#=:-")
+			l.acceptRun("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+#=:-" + figurines)
+			l.acceptEnPassant()
 			l.emit(itemSymbol)
 		}
 	}
 	return l.emitted
 }
 
+// acceptEnPassant consumes an en-passant marker ("e.p.") following a move,
+// either glued to the move or separated from it by spaces, along with any
+// check or mate sign following the marker (exd6 e.p.+). Annotations following
+// the marker are left to be lexed on their own.
+func (l *lexer) acceptEnPassant() {
+	rest := l.input[l.pos:]
+	if l.pos-l.start > 1 && l.input[l.pos-1] == 'e' && strings.HasPrefix(rest, ".p.") {
+		l.pos += len(".p.")
+		l.acceptRun("+#")
+		return
+	}
+	marker := strings.TrimLeft(rest, " \t")
+	if strings.HasPrefix(marker, "e.p.") {
+		l.pos += len(rest) - len(marker) + len("e.p.")
+		l.acceptRun("+#")
+	}
+}
+
 func (l *lexer) number() {
 	// Check if the number is not, in fact, a game result.
 	results := [...]string{"1-0", "0-1", "1/2-1/2"}
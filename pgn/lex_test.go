@@ -20,6 +20,11 @@ var lexTests = []lexTest{
 	{"spaces", " \t\r", []item{tEOF}},
 	{"pragma", "% ignore this line", []item{tEOF}},
 	{"line comment", "; line comment", []item{tEOF}},
+	{"escape after newline", "e4\n% ignore this line\ne5", []item{
+		{itemSymbol, "e4"},
+		{itemSymbol, "e5"},
+		tEOF,
+	}},
 	{"block comment", "{ block\ncomment }", []item{
 		{itemComment, "{ block\ncomment }"},
 		tEOF,
@@ -59,6 +64,14 @@ var lexTests = []lexTest{
 		{itemAnnotation, "?!"},
 		tEOF,
 	}},
+	{"en passant", "exd6 e.p. exd6 e.p.+ exd6e.p.# exd6 e.p.+!?", []item{
+		{itemSymbol, "exd6 e.p."},
+		{itemSymbol, "exd6 e.p.+"},
+		{itemSymbol, "exd6e.p.#"},
+		{itemSymbol, "exd6 e.p.+"},
+		{itemAnnotation, "!?"},
+		tEOF,
+	}},
 	{"escaped string", `[Event "a\"b"]`, []item{
 		{itemLBracket, "["},
 		{itemSymbol, "Event"},
@@ -72,6 +85,10 @@ var lexTests = []lexTest{
 		{itemSymbol, "Event"},
 		{itemNone, "unexpected character: U+0001"},
 	}},
+	{"escape mid-line", "e4 % not a comment\ne5", []item{
+		{itemSymbol, "e4"},
+		{itemNone, "unexpected character: U+0025 '%'"},
+	}},
 	{"unclosed string", `"casual game`, []item{
 		{itemNone, "unclosed quoted string"},
 	}},
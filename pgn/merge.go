@@ -0,0 +1,106 @@
+package pgn
+
+import "github.com/malbrecht/chess"
+
+// Merge combines games that start from the same position into a single game
+// tree. The main line of the first game becomes the main line of the result;
+// moves in which the games diverge are added as variations. Moves are compared
+// by the position they lead to, but only with the other moves from the same
+// position: identical sibling positions are merged, transpositions reached by
+// a different move order are not. Comments and NAGs of merged moves are
+// combined. Games whose movetext cannot be parsed, or that start from a
+// different position than the first game, are skipped. Merge returns nil if
+// there are no games to merge.
+func Merge(games []*Game) *Game {
+	var merged *Game
+	for _, g := range games {
+		if err := g.ParseMoves(); err != nil {
+			continue
+		}
+		if merged == nil {
+			tags := map[string]string{"Result": "*"}
+			for _, tag := range []string{"FEN", "SetUp"} {
+				if v, ok := g.Tags[tag]; ok {
+					tags[tag] = v
+				}
+			}
+			b := *g.Root.Board
+			merged = &Game{Tags: tags, Root: &Node{Board: &b}}
+		} else if !samePosition(merged.Root.Board, g.Root.Board) {
+			continue
+		}
+		mergeNode(merged.Root, g.Root)
+	}
+	return merged
+}
+
+// Merge merges all games in the database into a single game tree. See the
+// Merge function.
+func (d *DB) Merge() *Game {
+	return Merge(d.Games)
+}
+
+// mergeNode merges the moves following src into the tree following dst. dst
+// and src hold the same position.
+func mergeNode(dst, src *Node) {
+	mergeAnnotations(dst, src)
+	for _, s := range children(src) {
+		var d *Node
+		for _, c := range children(dst) {
+			if samePosition(c.Board, s.Board) {
+				d = c
+				break
+			}
+		}
+		if d == nil {
+			if dst.Next == nil {
+				d = dst.Insert(s.Move)
+			} else {
+				d = dst.Next.NewVariation().Insert(s.Move)
+			}
+		}
+		mergeNode(d, s)
+	}
+}
+
+// samePosition returns whether a and b hold the same position, comparing the
+// boards only if their hashes match.
+func samePosition(a, b *chess.Board) bool {
+	return a.Hash() == b.Hash() && a.Equal(b)
+}
+
+// mergeAnnotations adds the comments and NAGs of src to those of dst.
+func mergeAnnotations(dst, src *Node) {
+	dst.CommentBefore = mergeComments(dst.CommentBefore, src.CommentBefore)
+	dst.Comment = mergeComments(dst.Comment, src.Comment)
+	for _, nag := range src.Nags {
+		dst.AddNag(nag)
+	}
+}
+
+// mergeComments adds the comment paragraphs in src that are not yet in dst.
+func mergeComments(dst, src []string) []string {
+comments:
+	for _, c := range src {
+		for _, x := range dst {
+			if c == x {
+				continue comments
+			}
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// children returns the moves following n: the next move and its
+// alternatives.
+func children(n *Node) []*Node {
+	if n.Next == nil {
+		return nil
+	}
+	nodes := []*Node{n.Next}
+	for v := n.Next.Variation; v != nil && v.Next != nil; v = v.Next.Variation {
+		nodes = append(nodes, v.Next)
+	}
+	return nodes
+}
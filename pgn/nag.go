@@ -7,18 +7,25 @@ import (
 // Nag represents a numeric annotation glyph.
 type Nag int
 
-// String returns the common representation of the NAG if it has one (!, ?, !?,
-// +-, -+, ...). Otherwise it returns $<nag> ($56, $123, ...).
-func (n Nag) String() string {
-	if int(n) >= len(nagData) || nagData[n].str == "" {
+// Symbol returns the conventional glyph of the NAG if it has one (!, ?, !?,
+// ±, ∞, ...). Otherwise it returns $<nag> ($56, $123, ...).
+func (n Nag) Symbol() string {
+	if n < 0 || int(n) >= len(nagData) || nagData[n].str == "" {
 		return fmt.Sprintf("$%d", n)
 	}
 	return nagData[n].str
 }
 
-// Description returns a short description explaining the NAG.
+// String returns the glyph of the NAG, as Symbol does. Use Description for a
+// phrase describing it.
+func (n Nag) String() string {
+	return n.Symbol()
+}
+
+// Description returns a short phrase describing the NAG ("good move", "white
+// has a decisive advantage", ...).
 func (n Nag) Description() string {
-	if int(n) >= len(nagData) {
+	if n < 0 || int(n) >= len(nagData) {
 		return fmt.Sprintf("$%d: non-standard NAG", n)
 	}
 	return nagData[n].desc
@@ -36,11 +43,11 @@ var nagData = []struct {
 	5:   {"!?", "speculative move"},
 	6:   {"?!", "questionable move"},
 	7:   {"□", "forced move (all others lose quickly)"},
-	8:   {"", "singular move (no reasonable alternatives)"},
+	8:   {"□", "singular move (no reasonable alternatives)"},
 	9:   {"", "worst move"},
 	10:  {"=", "drawish position"},
-	11:  {"", "equal chances, quiet position"},
-	12:  {"", "equal chances, active position"},
+	11:  {"=", "equal chances, quiet position"},
+	12:  {"=", "equal chances, active position"},
 	13:  {"∞", "unclear position"},
 	14:  {"⩲", "white has a slight advantage"},
 	15:  {"⩱", "black has a slight advantage"},
@@ -50,8 +57,8 @@ var nagData = []struct {
 	19:  {"-+", "black has a decisive advantage"},
 	20:  {"+--", "white has a crushing advantage (black should resign)"},
 	21:  {"--+", "black has a crushing advantage (white should resign)"},
-	22:  {"", "white is in zugzwang"},
-	23:  {"", "black is in zugzwang"},
+	22:  {"⨀", "white is in zugzwang"},
+	23:  {"⨀", "black is in zugzwang"},
 	24:  {"", "white has a slight space advantage"},
 	25:  {"", "black has a slight space advantage"},
 	26:  {"", "white has a moderate space advantage"},
@@ -60,20 +67,20 @@ var nagData = []struct {
 	29:  {"", "black has a decisive space advantage"},
 	30:  {"", "white has a slight time (development) advantage"},
 	31:  {"", "black has a slight time (development) advantage"},
-	32:  {"", "white has a moderate time (development) advantage"},
-	33:  {"", "black has a moderate time (development) advantage"},
+	32:  {"⟳", "white has a moderate time (development) advantage"},
+	33:  {"⟳", "black has a moderate time (development) advantage"},
 	34:  {"", "white has a decisive time (development) advantage"},
 	35:  {"", "black has a decisive time (development) advantage"},
-	36:  {"", "white has the initiative"},
-	37:  {"", "black has the initiative"},
+	36:  {"↑", "white has the initiative"},
+	37:  {"↑", "black has the initiative"},
 	38:  {"", "white has a lasting initiative"},
 	39:  {"", "black has a lasting initiative"},
-	40:  {"", "white has the attack"},
-	41:  {"", "black has the attack"},
+	40:  {"→", "white has the attack"},
+	41:  {"→", "black has the attack"},
 	42:  {"", "white has insufficient compensation for material deficit"},
 	43:  {"", "black has insufficient compensation for material deficit"},
-	44:  {"", "white has sufficient compensation for material deficit"},
-	45:  {"", "black has sufficient compensation for material deficit"},
+	44:  {"=∞", "white has sufficient compensation for material deficit"},
+	45:  {"=∞", "black has sufficient compensation for material deficit"},
 	46:  {"", "white has more than adequate compensation for material deficit"},
 	47:  {"", "black has more than adequate compensation for material deficit"},
 	48:  {"", "white has a slight center control advantage"},
@@ -160,12 +167,12 @@ var nagData = []struct {
 	129: {"", "black has played the ending very well"},
 	130: {"", "white has slight counterplay"},
 	131: {"", "black has slight counterplay"},
-	132: {"", "white has moderate counterplay"},
-	133: {"", "black has moderate counterplay"},
+	132: {"⇆", "white has moderate counterplay"},
+	133: {"⇆", "black has moderate counterplay"},
 	134: {"", "white has decisive counterplay"},
 	135: {"", "black has decisive counterplay"},
 	136: {"", "white has moderate time control pressure"},
 	137: {"", "black has moderate time control pressure"},
-	138: {"", "white has severe time control pressure"},
-	139: {"", "black has severe time control pressure"},
+	138: {"⊕", "white has severe time control pressure"},
+	139: {"⊕", "black has severe time control pressure"},
 }
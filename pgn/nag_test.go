@@ -0,0 +1,36 @@
+package pgn
+
+import "testing"
+
+type nagTest struct {
+	nag    Nag
+	symbol string
+	desc   string
+}
+
+var nagTests = []nagTest{
+	{1, "!", "good move"},
+	{2, "?", "poor move"},
+	{3, "!!", "very good move"},
+	{4, "??", "very poor move"},
+	{5, "!?", "speculative move"},
+	{6, "?!", "questionable move"},
+	{14, "⩲", "white has a slight advantage"},
+	{19, "-+", "black has a decisive advantage"},
+	{56, "$56", "white has a moderate kingside control advantage"},
+	{300, "$300", "$300: non-standard NAG"},
+}
+
+func TestNag(t *testing.T) {
+	for _, test := range nagTests {
+		if s := test.nag.Symbol(); s != test.symbol {
+			t.Errorf("$%d: got symbol %q, want %q", int(test.nag), s, test.symbol)
+		}
+		if s := test.nag.String(); s != test.symbol {
+			t.Errorf("$%d: got string %q, want %q", int(test.nag), s, test.symbol)
+		}
+		if d := test.nag.Description(); d != test.desc {
+			t.Errorf("$%d: got description %q, want %q", int(test.nag), d, test.desc)
+		}
+	}
+}
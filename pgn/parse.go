@@ -2,6 +2,7 @@ package pgn
 
 import (
 	"fmt"
+	"github.com/malbrecht/chess"
 	"strconv"
 	"strings"
 )
@@ -12,6 +13,9 @@ type parser struct {
 	pos      int  // position of current item in input
 	item     item // current item
 	lastitem item // previous item
+	strict   bool // check that games conform to the PGN standard
+	lenient  bool // replace illegal moves by null moves
+	errs     []error
 }
 
 // ParseError describes a problem parsing a pgn file.
@@ -76,6 +80,14 @@ func (p *parser) next() {
 	p.item = p.lex.item()
 }
 
+// line returns the line number of p.pos. This can differ from p.lex.line, as
+// the lexer has already read the current item, which may be preceded by
+// newlines or span several lines itself (a block comment).
+func (p *parser) line() int {
+	line, _ := p.lex.coords(p.pos - p.lex.pos)
+	return line
+}
+
 // accept consumes an item (skipping comments) if it has the requested type.
 func (p *parser) accept(typ itemType) bool {
 	for p.item.typ == itemComment {
@@ -121,12 +133,20 @@ func (p *parser) readGame() (game *Game, err error) {
 	}
 	var (
 		mtext0    = p.pos
-		mtextline = p.lex.line
+		mtextline = p.line()
 		tags      = make(map[string]string)
 	)
+	tagpos := make(map[string]int) // positions of tag values
+	var order []string             // tag names in file order
+	// accept skips comments, so a comment block preceding the first tag,
+	// as written by some programs at the top of a file, is ignored.
 	for p.accept(itemLBracket) {
 		tag := p.expect(itemSymbol).val
+		tagpos[tag] = p.pos
 		val := p.expect(itemString).val
+		if _, ok := tags[tag]; !ok {
+			order = append(order, tag)
+		}
 		tags[tag] = unescape(val)
 		p.expect(itemRBracket)
 		// Remember where the movetext starts. Maintaining this inside
@@ -134,11 +154,14 @@ func (p *parser) readGame() (game *Game, err error) {
 		// skipped by the next accept() call, are included in the
 		// movetext.
 		mtext0 = p.pos
-		mtextline = p.lex.line
+		mtextline = p.line()
 	}
 	if len(tags) == 0 {
 		p.panicf("no game tags found")
 	}
+	if p.strict {
+		p.checkTags(tags, tagpos)
+	}
 	// Parsing and validating the moves in the movetext section is
 	// postponed until parseMoves is called. Here we just quickly scan the
 	// movetext to get some additional game information: the number of
@@ -176,11 +199,56 @@ loop:
 	if err != nil {
 		p.panicf("%s", err)
 	}
+	g.TagOrder = order
 	g.plies = plies
-	g.movelex = newLexer(p.lex.input[mtext0:mtext1], mtextline)
+	// The movetext lexer starts at the beginning of the line, so that it
+	// reports correct columns for the first line of the movetext.
+	bol := strings.LastIndexByte(p.lex.input[:mtext0], '\n') + 1
+	g.movelex = newLexer(p.lex.input[bol:mtext1], mtextline)
+	g.movelex.pos = mtext0 - bol
+	g.movelex.start = g.movelex.pos
 	return g, nil
 }
 
+// checkTags checks that the Seven Tag Roster is complete and that the Date and
+// Result tags are well-formed. tagpos holds the input positions of the tag
+// values, for error reporting.
+func (p *parser) checkTags(tags map[string]string, tagpos map[string]int) {
+	var missing []string
+	for _, tag := range sevenTagRoster {
+		if _, ok := tags[tag.name]; !ok {
+			missing = append(missing, tag.name)
+		}
+	}
+	if len(missing) > 0 {
+		p.panicf("missing tags: %s", strings.Join(missing, ", "))
+	}
+	if date := tags["Date"]; !isDate(date) {
+		p.pos = tagpos["Date"]
+		p.panicf("Date tag %q is not in YYYY.MM.DD format", date)
+	}
+	switch result := tags["Result"]; result {
+	case "1-0", "0-1", "1/2-1/2", "*":
+	default:
+		p.pos = tagpos["Result"]
+		p.panicf("invalid Result tag %q", result)
+	}
+}
+
+// isDate returns whether s is a date in PGN format: YYYY.MM.DD, where any of
+// the digits can be replaced by question marks if unknown.
+func isDate(s string) bool {
+	if len(s) != 10 || s[4] != '.' || s[7] != '.' {
+		return false
+	}
+	for i, c := range s {
+		if i != 4 && i != 7 && c != '?' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
 // parseMoves parses a movetext section, knowing that p.lex has been set up to
 // lex a single such section.
 func (p *parser) parseMoves(root *Node) (err error) {
@@ -194,16 +262,44 @@ func (p *parser) parseMoves(root *Node) (err error) {
 
 // variation parses recursive variations (lists of moves).
 func (p *parser) variation(node *Node, level int) {
+	// A comment following a move (and its annotations) is about that
+	// move. Comments at the start of the variation belong to its root.
+	// Comments after a move number or after a variation are kept until
+	// the next move, for which they are preceding comments.
+	var (
+		preceding = true // comments precede the next move
+		before    []string
+	)
+	// flush adds preceding comments that are not followed by another
+	// move to the last move.
+	flush := func() {
+		node.Comment = append(node.Comment, before...)
+		before = nil
+	}
 	for {
 		switch p.item.typ {
 		case itemSymbol: // a move
 			move, err := node.Board.ParseMove(p.item.val)
-			if err != nil {
+			if err != nil && p.lenient {
+				line, col := p.lex.coords(p.pos - p.lex.pos)
+				p.errs = append(p.errs, &ParseError{
+					Line:    line,
+					Col:     col,
+					Message: fmt.Sprintf("%q: %s", p.item.val, err),
+				})
+				move = chess.NullMove
+			} else if err != nil {
 				p.panicf("%q: %s", p.item.val, err)
 			}
 			node = node.Insert(move)
+			node.CommentBefore = before
+			before, preceding = nil, false
 		case itemComment:
-			node.Comment = append(node.Comment, unquote(p.item.val))
+			if preceding && !node.IsRoot() {
+				before = append(before, unquote(p.item.val))
+			} else {
+				node.Comment = append(node.Comment, unquote(p.item.val))
+			}
 		case itemAnnotation:
 			node.AddNag(p.nag(p.item.val))
 		case itemLParen:
@@ -212,17 +308,22 @@ func (p *parser) variation(node *Node, level int) {
 			}
 			p.next()
 			p.variation(node.NewVariation(), level+1)
+			preceding = true
 		case itemRParen:
 			if level == 0 {
 				p.panicf("unexpected right parenthesis")
 			}
+			flush()
 			return
 		case itemEOF, itemLBracket:
 			if level != 0 {
 				p.panicf("%d unclosed variations", level)
 			}
+			flush()
 			return
-		case itemMoveNumber, itemDots, itemResult:
+		case itemMoveNumber, itemDots:
+			preceding = true
+		case itemResult:
 			// ignore
 		default:
 			p.panicf("unexpected token: %s", p.item.typ)
@@ -18,6 +18,7 @@ type ttags map[string]string
 
 type tnode struct {
 	move      string
+	before    string
 	comment   string
 	nags      []int
 	variation []tnode
@@ -221,6 +222,101 @@ var parseTests = []parseTest{
 		}}},
 		nil,
 	},
+	{"comment placement",
+		`[Result "*"] 1. e4 {post} (1. d4 {d4}) {pre} 1... e5 (1... c5) {c5}`,
+
+		[]tgame{{ttags{
+			"Result": "*",
+		}, []tnode{
+			{move: "--"},
+			{move: "e4", comment: "post", variation: []tnode{
+				{move: "--"}, {move: "d4", comment: "d4"},
+			}},
+			{move: "e5", before: "pre", comment: "c5", variation: []tnode{
+				{move: "--"}, {move: "c5"},
+			}},
+		}}},
+		nil,
+	},
+	{"comment-only variation",
+		`[Result "*"] 1. e4 ({only a comment}) e5 *`,
+
+		[]tgame{{ttags{
+			"Result": "*",
+		}, []tnode{
+			{move: "--"},
+			{move: "e4", variation: []tnode{
+				{move: "--", comment: "only a comment"},
+			}},
+			{move: "e5"},
+		}}},
+		nil,
+	},
+	{"en-passant marker",
+		`[Result "*"] 1. e4 a6 2. e5 d5 3. exd6 e.p. Qxd6 4. d4 Qd8 5. d5 c5 6. dxc6e.p. *`,
+
+		[]tgame{{ttags{
+			"Result": "*",
+		}, []tnode{
+			{move: "--"},
+			{move: "e4"},
+			{move: "a6"},
+			{move: "e5"},
+			{move: "d5"},
+			{move: "exd6"},
+			{move: "Qxd6"},
+			{move: "d4"},
+			{move: "Qd8"},
+			{move: "d5"},
+			{move: "c5"},
+			{move: "dxc6"},
+		}}},
+		nil,
+	},
+	{"null moves",
+		`[Result "*"] 1. e4 (1. -- e5) (1. Z0 d5) *`,
+
+		[]tgame{{ttags{
+			"Result": "*",
+		}, []tnode{
+			{move: "--"},
+			{move: "e4", variation: []tnode{
+				{move: "--"},
+				{move: "--", variation: []tnode{
+					{move: "--"},
+					{move: "--"},
+					{move: "d5"},
+				}},
+				{move: "e5"},
+			}},
+		}}},
+		nil,
+	},
+	{"comment before tags",
+		"{header}\n; exported games\n[Event \"x\"] [Result \"*\"] 1. e4 *",
+
+		[]tgame{{ttags{
+			"Event":  "x",
+			"Result": "*",
+		}, []tnode{
+			{move: "--"},
+			{move: "e4"},
+		}}},
+		nil,
+	},
+	{"comment before and after",
+		`[Result "*"] {pre} e4 {post} e5 {e5} 2. {Nf3} Nf3 *`,
+
+		[]tgame{{ttags{
+			"Result": "*",
+		}, []tnode{
+			{move: "--", comment: "pre"},
+			{move: "e4", comment: "post"},
+			{move: "e5", comment: "e5"},
+			{move: "Nf3", before: "Nf3"},
+		}}},
+		nil,
+	},
 	{"unescape string",
 		`[Event "a\"b"] [Result "*"] 1. e4 e5 2. Nf3 *`,
 
@@ -273,6 +369,12 @@ var parseTests = []parseTest{
 			`4:1: no game tags found`,
 		},
 	},
+	{"error after multi-line comment",
+		"[Result \"*\"]\n1. e4 {a comment\nspanning two lines} Ke7 2. Nf3 *",
+
+		nil,
+		[]string{`3:20: "Ke7": invalid move`},
+	},
 	{"game result mismatch",
 		`[Result "1-0"] 1. e4 e5 2. Nf3 1/2-1/2`,
 
@@ -319,6 +421,7 @@ func collectVariation(root *Node) []tnode {
 		nodes = append(nodes, tnode{
 			move:      move,
 			nags:      nags,
+			before:    strings.Join(nd.CommentBefore, " "),
 			comment:   strings.Join(nd.Comment, " "),
 			variation: collectVariation(nd.Variation),
 		})
@@ -341,3 +444,38 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+type strictTest struct {
+	name   string
+	input  string
+	games  int
+	errors []string
+}
+
+const strictTags = `[Event "?"] [Site "?"] [Round "?"] [White "John"] `
+
+var strictTests = []strictTest{
+	{"complete", strictTags + `[Black "Jane"] [Date "2021.??.??"] [Result "*"] 1. e4 *`, 1, nil},
+	{"missing Black tag", strictTags + `[Date "2021.03.04"] [Result "*"] 1. e4 *`, 0,
+		[]string{`1:83: missing tags: Black`}},
+	{"malformed Date", strictTags + `[Black "Jane"] [Date "4 March 2021"] [Result "*"] 1. e4 *`, 0,
+		[]string{`1:71: Date tag "4 March 2021" is not in YYYY.MM.DD format`}},
+	{"invalid Result", strictTags + `[Black "Jane"] [Date "2021.03.04"] [Result "1:0"] *`, 0,
+		[]string{`1:93: invalid Result tag "1:0"`}},
+}
+
+func TestParseStrict(t *testing.T) {
+	for _, test := range strictTests {
+		var db DB
+		var errors []string
+		for _, err := range db.ParseStrict(test.input) {
+			errors = append(errors, err.Error())
+		}
+		if len(db.Games) != test.games {
+			t.Errorf("%s: got %d games, want %d", test.name, len(db.Games), test.games)
+		}
+		if !reflect.DeepEqual(errors, test.errors) {
+			t.Errorf("%s: got errors %q, want %q", test.name, errors, test.errors)
+		}
+	}
+}
@@ -3,8 +3,14 @@
 package pgn
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/malbrecht/chess"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // DB represents a collection of chess games. Its zero value is an empty
@@ -18,6 +24,12 @@ type Game struct {
 	// Tags holds the PGN tags for the game.
 	Tags map[string]string
 
+	// TagOrder lists the names of the tags in the order in which they
+	// appeared in the PGN file. Game export writes tags that are not part
+	// of the Seven Tag Roster in this order; tags missing from TagOrder
+	// follow, sorted by name.
+	TagOrder []string
+
 	// Root is the root node of the main variation of the game. Root.Board
 	// is the starting position of the game.
 	Root *Node
@@ -41,14 +53,20 @@ type Game struct {
 // of the variation. Use IsRoot to determine whether the node is the root node
 // of a variation. Note that following Next never leads to a root node, and
 // following Variation always leads to a root node.
+//
+// Comments that follow a move are about that move and are kept in Comment.
+// Comments that precede a move other than the first of a variation, because
+// they appear after a move number or after the previous move's variations,
+// are kept in CommentBefore.
 type Node struct {
-	Parent    *Node        // previous move
-	Next      *Node        // next move
-	Variation *Node        // an alternative to this move
-	Move      chess.Move   // this move
-	Board     *chess.Board // position after Move
-	Comment   []string     // comment paragraphs on the move
-	Nags      []Nag        // annotations
+	Parent        *Node        // previous move
+	Next          *Node        // next move
+	Variation     *Node        // an alternative to this move
+	Move          chess.Move   // this move
+	Board         *chess.Board // position after Move
+	Comment       []string     // comment paragraphs on the move
+	CommentBefore []string     // comment paragraphs preceding the move
+	Nags          []Nag        // annotations
 }
 
 // NewGame initializes a new chess game. The starting position of the game, if
@@ -79,6 +97,182 @@ func (g *Game) Plies() int {
 	return plies
 }
 
+// MainLine returns the nodes of the main line of the game, excluding the root
+// node.
+func (g *Game) MainLine() []*Node {
+	var nodes []*Node
+	for n := g.Root.Next; n != nil; n = n.Next {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Moves returns the moves of the main line of the game.
+func (g *Game) Moves() []chess.Move {
+	var moves []chess.Move
+	for n := g.Root.Next; n != nil; n = n.Next {
+		moves = append(moves, n.Move)
+	}
+	return moves
+}
+
+// Termination returns how the game ended: "checkmate", "stalemate",
+// "insufficient" (material), "threefold" (repetition), "fifty-move",
+// "resignation", "time forfeit", "agreement" or "unterminated". A Result tag
+// of "*" or a Termination tag of "unterminated" means "unterminated", and a
+// Termination tag mentioning time means "time forfeit". The other Termination
+// tags of the PGN standard, "abandoned", "adjudication", "death", "emergency"
+// and "rules infraction", are returned in lower case, and any other tag except
+// "normal" as "unknown". Otherwise the reason is inferred from the final
+// position of the main line, and then from the result: a decisive game was
+// resigned and a drawn game agreed. The game is not changed: if its movetext
+// has not been parsed yet, a copy is parsed. An empty string is returned if
+// the movetext cannot be parsed.
+func (g *Game) Termination() string {
+	result := g.Tags["Result"]
+	tag := strings.ToLower(strings.TrimSpace(g.Tags["Termination"]))
+	switch {
+	case result == "*" || result == "" || tag == "unterminated":
+		return "unterminated"
+	case strings.Contains(tag, "time"):
+		return "time forfeit"
+	case tag == "abandoned" || tag == "adjudication" || tag == "death" ||
+		tag == "emergency" || tag == "rules infraction":
+		return tag
+	case tag != "" && tag != "normal":
+		return "unknown"
+	}
+	root := g.Root
+	if g.movelex != nil {
+		lex, parsed := *g.movelex, *g.Root
+		p := &parser{lex: &lex}
+		if err := p.parseMoves(&parsed); err != nil {
+			return ""
+		}
+		root = &parsed
+	}
+	last := root
+	for last.Next != nil {
+		last = last.Next
+	}
+	b := last.Board
+	check, mate := b.IsCheckOrMate()
+	switch {
+	case mate && check:
+		return "checkmate"
+	case mate:
+		return "stalemate"
+	case b.InsufficientMaterial():
+		return "insufficient"
+	case result == "1/2-1/2" && last.IsThreefoldRepetition():
+		return "threefold"
+	case result == "1/2-1/2" && b.FiftyMoveDraw():
+		return "fifty-move"
+	case result == "1/2-1/2":
+		return "agreement"
+	}
+	return "resignation"
+}
+
+// Round parses the Round tag in its conventional forms "3" (round 3) and
+// "3.1" (round 3, board 1). The board number is 0 if the tag has none. ok is
+// false if the tag is missing, unknown ("?"), inapplicable ("-") or not of
+// this form.
+func (g *Game) Round() (round, board int, ok bool) {
+	tag := strings.TrimSpace(g.Tags["Round"])
+	r, b := tag, ""
+	if i := strings.IndexByte(tag, '.'); i >= 0 {
+		r, b = tag[:i], tag[i+1:]
+		var err error
+		if board, err = strconv.Atoi(b); err != nil || board < 1 {
+			return 0, 0, false
+		}
+	}
+	round, err := strconv.Atoi(r)
+	if err != nil || round < 0 {
+		return 0, 0, false
+	}
+	return round, board, true
+}
+
+// WhiteElo returns White's rating from the WhiteElo tag. ok is false if the
+// tag is missing or holds no rating, as "-" for an unrated player.
+func (g *Game) WhiteElo() (elo int, ok bool) {
+	return g.elo("WhiteElo")
+}
+
+// BlackElo returns Black's rating from the BlackElo tag, see WhiteElo.
+func (g *Game) BlackElo() (elo int, ok bool) {
+	return g.elo("BlackElo")
+}
+
+// AverageElo returns the average rating of the players. ok is false unless
+// both ratings are known.
+func (g *Game) AverageElo() (elo int, ok bool) {
+	white, ok := g.WhiteElo()
+	if !ok {
+		return 0, false
+	}
+	black, ok := g.BlackElo()
+	if !ok {
+		return 0, false
+	}
+	return (white + black) / 2, true
+}
+
+func (g *Game) elo(tag string) (int, bool) {
+	elo, err := strconv.Atoi(strings.TrimSpace(g.Tags[tag]))
+	if err != nil || elo <= 0 {
+		return 0, false
+	}
+	return elo, true
+}
+
+// DatePrecision tells which parts of a date are known.
+type DatePrecision int
+
+const (
+	DateUnknown DatePrecision = iota
+	DateYear                  // only the year is known
+	DateMonth                 // the year and month are known
+	DateDay                   // the full date is known
+)
+
+// Date parses the Date tag, which is in the form YYYY.MM.DD with unknown
+// parts written as question marks, as in "2023.07.??" or "????.??.??". The
+// unknown month or day of a partial date is taken as 1, and precision tells
+// which parts are known. ok is false if the tag is missing, invalid or the
+// year is unknown.
+func (g *Game) Date() (t time.Time, precision DatePrecision, ok bool) {
+	tag := g.Tags["Date"]
+	if !isDate(tag) {
+		return time.Time{}, DateUnknown, false
+	}
+	var fields [3]int
+	for i, f := range strings.Split(tag, ".") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			break // unknown, so are the parts following it
+		}
+		fields[i] = n
+		precision++
+	}
+	year, month, day := fields[0], fields[1], fields[2]
+	switch precision {
+	case DateUnknown:
+		return time.Time{}, DateUnknown, false
+	case DateYear:
+		month, day = 1, 1
+	case DateMonth:
+		day = 1
+	}
+	t = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Month() != time.Month(month) || t.Day() != day {
+		return time.Time{}, DateUnknown, false // as in 2023.02.30
+	}
+	return t, precision, true
+}
+
 // Insert adds a node to the game tree, as a child of n. The new node is
 // returned so that consecutive moves can be added like
 //     n := game.Root
@@ -130,6 +324,50 @@ func (n *Node) Variations() []*Node {
 	return vs
 }
 
+// MoveNumber returns the move number of the node's move and whether it is a
+// move by Black, for formatting the move as "12." or "12...". For a root node,
+// which has no move, it returns the number of the first move of the
+// variation.
+func (n *Node) MoveNumber() (number int, black bool) {
+	b := n.Board
+	if !n.IsRoot() {
+		b = n.Parent.Board
+	}
+	return b.MoveNr, b.SideToMove == chess.Black
+}
+
+// RepetitionCount returns how many times the position after the node's move
+// has occurred in the game, following the moves that led to it (through
+// Parent) back to the start of the game. Positions are the same if they have
+// the same pieces on the same squares, the same side to move and the same
+// castling and en-passant capture possibilities.
+func (n *Node) RepetitionCount() int {
+	for n.Parent != nil && n.IsRoot() {
+		n = n.Parent // a variation root repeats the board of its parent
+	}
+	hash := n.Board.Hash()
+	count := 0
+	for p := n; p != nil; p = p.Parent {
+		if p.Parent != nil && p.IsRoot() {
+			continue
+		}
+		if p.Board.Hash() == hash {
+			count++
+		}
+		if p.Board.Rule50 == 0 {
+			break // no repetitions possible across pawn moves and captures
+		}
+	}
+	return count
+}
+
+// IsThreefoldRepetition returns whether the position after the node's move has
+// occurred (at least) three times in the game, which allows a draw to be
+// claimed.
+func (n *Node) IsThreefoldRepetition() bool {
+	return n.RepetitionCount() >= 3
+}
+
 // IsRoot returns whether the node is the root node of a variation.
 func (n *Node) IsRoot() bool {
 	return n.Parent == nil || n.Parent.Next != n
@@ -163,8 +401,21 @@ func (n *Node) DropNag(nag Nag) {
 // section of each game is loaded, use ParseMoves on each individual game to
 // parse the movetext. Parse returns a list of encountered ParseErrors.
 func (d *DB) Parse(text string) []error {
+	return d.parse(&parser{lex: newLexer(text, 1)})
+}
+
+// ParseStrict is like Parse, but additionally checks that each game conforms
+// to the PGN standard: the tags of the Seven Tag Roster (Event, Site, Date,
+// Round, White, Black and Result) must be present, the Date tag must be in
+// YYYY.MM.DD format (with question marks for unknown parts) and the Result tag
+// must be one of 1-0, 0-1, 1/2-1/2 or *. Games that do not conform are
+// reported as ParseErrors and not added to the database.
+func (d *DB) ParseStrict(text string) []error {
+	return d.parse(&parser{lex: newLexer(text, 1), strict: true})
+}
+
+func (d *DB) parse(p *parser) []error {
 	var errs []error
-	p := &parser{lex: newLexer(text, 1)}
 	for {
 		game, err := p.readGame()
 		if err != nil {
@@ -179,18 +430,68 @@ func (d *DB) Parse(text string) []error {
 	return errs
 }
 
+// ParseFile is like Parse, but reads the PGN games from the named file. A
+// leading UTF-8 byte order mark is skipped, and files that are not valid UTF-8
+// are assumed to be encoded in ISO-8859-1 (Latin-1) and converted to UTF-8.
+func (d *DB) ParseFile(path string) []error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	return d.Parse(decode(data))
+}
+
+// decode converts PGN file contents to a UTF-8 string, see ParseFile.
+func decode(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	// Latin-1 bytes map one-to-one onto the first 256 unicode code points.
+	runes := make([]rune, len(data))
+	for i, c := range data {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
 // ParseMoves parses the movetext section of the game, generating the game tree
 // in game.Root.
 func (d *DB) ParseMoves(game *Game) error {
-	if game.movelex == nil {
+	return game.ParseMoves()
+}
+
+// ParseMoves parses the movetext section of a game read from a PGN file,
+// generating the game tree in g.Root. It does nothing if the movetext has
+// already been parsed.
+func (g *Game) ParseMoves() error {
+	if g.movelex == nil {
 		return nil
 	}
-	p := &parser{lex: game.movelex}
-	oldroot := *game.Root
-	if err := p.parseMoves(game.Root); err != nil {
-		game.Root = &oldroot
+	p := &parser{lex: g.movelex}
+	oldroot := *g.Root
+	if err := p.parseMoves(g.Root); err != nil {
+		g.Root = &oldroot
 		return err
 	}
-	game.movelex = nil
+	g.movelex = nil
 	return nil
 }
+
+// ParseMovesLenient is like ParseMoves, but does not give up on illegal or
+// unreadable moves. Such moves are replaced by null moves, so that the rest of
+// the game is still read, and reported as ParseErrors. Other errors still
+// abort parsing the movetext.
+func (g *Game) ParseMovesLenient() []error {
+	if g.movelex == nil {
+		return nil
+	}
+	p := &parser{lex: g.movelex, lenient: true}
+	oldroot := *g.Root
+	if err := p.parseMoves(g.Root); err != nil {
+		g.Root = &oldroot
+		return append(p.errs, err)
+	}
+	g.movelex = nil
+	return p.errs
+}
@@ -0,0 +1,330 @@
+package pgn
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMainLine(t *testing.T) {
+	var db DB
+	if errs := db.Parse(operaGame); errs != nil {
+		t.Fatal(errs)
+	}
+	game := db.Games[0]
+	plies := game.Plies()
+	if err := game.ParseMoves(); err != nil {
+		t.Fatal(err)
+	}
+	nodes, moves := game.MainLine(), game.Moves()
+	if len(nodes) != plies || len(moves) != plies {
+		t.Fatalf("got %d nodes and %d moves, want %d", len(nodes), len(moves), plies)
+	}
+	for i, n := range nodes {
+		if n.Move != moves[i] {
+			t.Errorf("ply %d: node move %v differs from %v", i, n.Move, moves[i])
+		}
+	}
+	if san := moves[plies-1].San(nodes[plies-1].Parent.Board); san != "Rd8#" {
+		t.Errorf("last move: got %s, want Rd8#", san)
+	}
+}
+
+func TestTermination(t *testing.T) {
+	tests := []struct {
+		pgn, want string
+	}{
+		{operaGame, "checkmate"},
+		{`[Result "*"] 1. e4 e5 2. Nf3 *`, "unterminated"},
+		{`[Result "1-0"] 1. e4 e5 2. Nf3 1-0`, "resignation"},
+		{`[Result "1-0"] [Termination "Time forfeit"] 1. e4 e5 2. Nf3 1-0`, "time forfeit"},
+		{`[Result "1/2-1/2"] 1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3 Nf6 4. Ng1 Ng8 1/2-1/2`, "threefold"},
+		{`[Result "1/2-1/2"] 1. e4 e5 1/2-1/2`, "agreement"},
+		{`[FEN "7k/4Q3/6K1/8/8/8/8/8 w - - 0 1"] [Result "1/2-1/2"] 1. Qf7 1/2-1/2`, "stalemate"},
+		{`[FEN "7k/5q2/6K1/8/8/8/8/8 w - - 0 1"] [Result "1/2-1/2"] 1. Kxf7 1/2-1/2`, "insufficient"},
+		{`[Result "0-1"] 1. f3 e5 2. g4 Qh4# 0-1`, "checkmate"},
+		{`[Result "0-1"] [Termination "Normal"] 1. f3 e5 2. g4 Qh4# 0-1`, "checkmate"},
+		{`[Result "1-0"] [Termination "Abandoned"] 1. e4 e5 1-0`, "abandoned"},
+		{`[Result "0-1"] [Termination "Rules infraction"] 1. e4 e5 0-1`, "rules infraction"},
+		{`[Result "1-0"] [Termination "Disconnected"] 1. e4 e5 1-0`, "unknown"},
+		{`[Result "1-0"] 1. e4 e5 2. Kf3 1-0`, ""},
+	}
+	for _, test := range tests {
+		var db DB
+		if errs := db.Parse(test.pgn); errs != nil {
+			t.Fatal(errs)
+		}
+		g := db.Games[0]
+		if got := g.Termination(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.pgn, got, test.want)
+		}
+		if g.Root.Next != nil {
+			t.Errorf("%s: Termination parsed the moves of the game", test.pgn)
+		}
+		if err := g.ParseMoves(); err == nil && g.Termination() != test.want {
+			t.Errorf("%s: got %q after ParseMoves, want %q", test.pgn, g.Termination(), test.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		tag          string
+		round, board int
+		ok           bool
+	}{
+		{"3", 3, 0, true},
+		{"3.1", 3, 1, true},
+		{"12.10", 12, 10, true},
+		{"?", 0, 0, false},
+		{"-", 0, 0, false},
+		{"", 0, 0, false},
+		{"3.", 0, 0, false},
+		{"1-3", 0, 0, false},
+	}
+	for _, test := range tests {
+		g := &Game{Tags: map[string]string{"Round": test.tag}}
+		round, board, ok := g.Round()
+		if round != test.round || board != test.board || ok != test.ok {
+			t.Errorf("%q: got %d, %d, %v, want %d, %d, %v",
+				test.tag, round, board, ok, test.round, test.board, test.ok)
+		}
+	}
+}
+
+func TestElo(t *testing.T) {
+	tests := []struct {
+		white, black string
+		whiteOK      bool
+		blackOK      bool
+		average      int
+		averageOK    bool
+	}{
+		{"2700", "2651", true, true, 2675, true},
+		{"2700", "", true, false, 0, false},
+		{"-", "1800", false, true, 0, false},
+		{"?", "-", false, false, 0, false},
+	}
+	for _, test := range tests {
+		g := &Game{Tags: map[string]string{}}
+		if test.white != "" {
+			g.Tags["WhiteElo"] = test.white
+		}
+		if test.black != "" {
+			g.Tags["BlackElo"] = test.black
+		}
+		if elo, ok := g.WhiteElo(); ok != test.whiteOK || ok && strconv.Itoa(elo) != test.white {
+			t.Errorf("WhiteElo %q: got %d, %v", test.white, elo, ok)
+		}
+		if elo, ok := g.BlackElo(); ok != test.blackOK || ok && strconv.Itoa(elo) != test.black {
+			t.Errorf("BlackElo %q: got %d, %v", test.black, elo, ok)
+		}
+		if elo, ok := g.AverageElo(); elo != test.average || ok != test.averageOK {
+			t.Errorf("AverageElo %q, %q: got %d, %v, want %d, %v",
+				test.white, test.black, elo, ok, test.average, test.averageOK)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		tag       string
+		date      string
+		precision DatePrecision
+		ok        bool
+	}{
+		{"2023.07.15", "2023-07-15", DateDay, true},
+		{"2023.07.??", "2023-07-01", DateMonth, true},
+		{"2023.??.??", "2023-01-01", DateYear, true},
+		{"2023.??.15", "2023-01-01", DateYear, true},
+		{"????.??.??", "", DateUnknown, false},
+		{"19??.??.??", "", DateUnknown, false},
+		{"2023.02.30", "", DateUnknown, false},
+		{"2023-07-15", "", DateUnknown, false},
+		{"", "", DateUnknown, false},
+	}
+	for _, test := range tests {
+		g := &Game{Tags: map[string]string{"Date": test.tag}}
+		date, precision, ok := g.Date()
+		var want time.Time
+		if test.date != "" {
+			want, _ = time.Parse("2006-01-02", test.date)
+		}
+		if !date.Equal(want) || precision != test.precision || ok != test.ok {
+			t.Errorf("%q: got %v, %d, %v, want %v, %d, %v",
+				test.tag, date, precision, ok, want, test.precision, test.ok)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var db DB
+	errs := db.Parse(`
+		[Result "1-0"] 1. e4 e5 2. Nf3 {main} Nc6 3. Bb5 1-0
+		[Result "0-1"] 1. e4 e5 2. Nf3 $1 {alternative} Nf6 3. Nxe5 0-1
+		[Result "*"] 1. e4 e5 2. Nf3 Nc6 3. Bc4 *
+		[FEN "8/8/8/8/8/8/8/K6k w - - 0 1"] 1. Kb1 *`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	game := db.Merge()
+	want := []tnode{
+		{move: "--"},
+		{move: "e4"},
+		{move: "e5"},
+		{move: "Nf3", nags: []int{1}, comment: "main alternative"},
+		{move: "Nc6", variation: []tnode{{move: "--"}, {move: "Nf6"}, {move: "Nxe5"}}},
+		{move: "Bb5", variation: []tnode{{move: "--"}, {move: "Bc4"}}},
+	}
+	if got := collectVariation(game.Root); !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect game tree\ngot:  %v\nwant: %v", got, want)
+	}
+
+	// transpositions are not merged
+	db = DB{}
+	if errs := db.Parse(`[Result "*"] 1. e4 e5 2. Nf3 * [Result "*"] 1. Nf3 e5 2. e4 *`); errs != nil {
+		t.Fatal(errs)
+	}
+	want = []tnode{
+		{move: "--"},
+		{move: "e4", variation: []tnode{{move: "--"}, {move: "Nf3"}, {move: "e5"}, {move: "e4"}}},
+		{move: "e5"},
+		{move: "Nf3"},
+	}
+	if got := collectVariation(db.Merge().Root); !reflect.DeepEqual(got, want) {
+		t.Errorf("transposition: incorrect game tree\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var db DB
+	errs := db.Parse(`
+		[White "Carlsen, Magnus"] [Black "Caruana, Fabiano"] [Result "1-0"] 1-0
+		[White "Caruana, Fabiano"] [Black "Carlsen, Magnus"] [Result "1-0"] 1-0
+		[White "Nakamura, Hikaru"] [Black "Carlsen, Magnus"] [Result "0-1"] 0-1
+		[White "Nakamura, Hikaru"] [Black "Caruana, Fabiano"] [Result "1/2-1/2"] 1/2-1/2`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	count := func(pred func(*Game) bool) int { return len(db.Filter(pred)) }
+	if n := count(ByTag("Result", "1-0")); n != 2 {
+		t.Errorf("ByTag: got %d games, want 2", n)
+	}
+	if n := count(ByPlayer("carlsen")); n != 3 {
+		t.Errorf("ByPlayer: got %d games, want 3", n)
+	}
+	if n := count(ByWinner("Carlsen")); n != 2 {
+		t.Errorf("ByWinner: got %d games, want 2", n)
+	}
+	if n := count(ByPlayer("")); n != 0 {
+		t.Errorf("ByPlayer with empty name: got %d games, want 0", n)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	for _, file := range []string{"testdata/bom.pgn", "testdata/latin1.pgn"} {
+		var db DB
+		if errs := db.ParseFile(file); errs != nil {
+			t.Errorf("%s: %v", file, errs)
+			continue
+		}
+		if len(db.Games) != 1 {
+			t.Errorf("%s: got %d games, want 1", file, len(db.Games))
+			continue
+		}
+		if white := db.Games[0].Tags["White"]; white != "Jürgen" {
+			t.Errorf("%s: got White %q, want %q", file, white, "Jürgen")
+		}
+	}
+	var db DB
+	if errs := db.ParseFile("testdata/nonexistent.pgn"); len(errs) != 1 {
+		t.Errorf("nonexistent file: got errors %v", errs)
+	}
+}
+
+func TestMoveNumber(t *testing.T) {
+	var db DB
+	errs := db.Parse(`[FEN "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"]
+		1... e5 2. Nf3 (2. Nc3 Nc6 (2... Nf6)) *`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	game := db.Games[0]
+	if err := game.ParseMoves(); err != nil {
+		t.Fatal(err)
+	}
+	type moveNumber struct {
+		number int
+		black  bool
+	}
+	check := func(name string, n *Node, want moveNumber) {
+		if number, black := n.MoveNumber(); number != want.number || black != want.black {
+			t.Errorf("%s: got %d, %v, want %d, %v", name, number, black, want.number, want.black)
+		}
+	}
+	e5 := game.Root.Next
+	check("root", game.Root, moveNumber{1, true})
+	check("e5", e5, moveNumber{1, true})
+	check("Nf3", e5.Next, moveNumber{2, false})
+	v := e5.Next.Variations()[0]
+	check("variation root", v, moveNumber{2, false})
+	nc6 := v.Next.Next
+	check("Nc6", nc6, moveNumber{2, true})
+	check("Nf6", nc6.Variations()[0].Next, moveNumber{2, true})
+}
+
+func TestRepetition(t *testing.T) {
+	var db DB
+	errs := db.Parse(`[Result "*"]
+		1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3 Nf6 4. Ng1 (4. e4 Ng8 5. Ng1) Ng8 *`)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	game := db.Games[0]
+	if err := game.ParseMoves(); err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 1, 1, 2, 2, 2, 2, 3}
+	for i, n := range game.MainLine() {
+		if count := n.RepetitionCount(); count != want[i] {
+			t.Errorf("ply %d: got %d repetitions, want %d", i+1, count, want[i])
+		}
+		if rep := n.IsThreefoldRepetition(); rep != (want[i] >= 3) {
+			t.Errorf("ply %d: got threefold repetition %v", i+1, rep)
+		}
+	}
+	// in the variation, 4. e4 resets the 50-move counter
+	v := game.MainLine()[6].Variations()[0]
+	for n := v.Next; n != nil; n = n.Next {
+		if count := n.RepetitionCount(); count != 1 {
+			t.Errorf("variation %s: got %d repetitions, want 1",
+				n.Move.San(n.Parent.Board), count)
+		}
+	}
+}
+
+func TestParseMovesLenient(t *testing.T) {
+	const text = `[Result "*"] 1. e4 e5 2. Nf3 Bx9 3. Bc4 Nf6 *`
+	var db DB
+	db.Parse(text)
+	if err := db.Games[0].ParseMoves(); err == nil {
+		t.Error("ParseMoves: illegal move accepted")
+	}
+
+	db = DB{}
+	db.Parse(text)
+	g := db.Games[0]
+	errs := g.ParseMovesLenient()
+	if len(errs) != 1 || errs[0].Error() != `1:29: "Bx9": invalid move` {
+		t.Errorf("got errors %q", errs)
+	}
+	var moves []string
+	for _, n := range g.MainLine() {
+		moves = append(moves, n.Move.San(n.Parent.Board))
+	}
+	if got, want := strings.Join(moves, " "), "e4 e5 Nf3 -- Bc4 Nf6"; got != want {
+		t.Errorf("got moves %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package pgn
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Scanner reads PGN games one at a time from an io.Reader, so that arbitrarily
+// large PGN files can be processed without loading them into memory as a
+// whole. Like DB.Parse, only the tag section of each game is parsed; use
+// Game.ParseMoves to parse the movetext.
+type Scanner struct {
+	rd      *bufio.Reader
+	line    int     // line number of the next line to be read
+	pending string  // first line of the next game, already read
+	p       *parser // parser for the current chunk of input
+	err     error   // read error
+}
+
+// Scan returns a Scanner reading from r.
+func Scan(r io.Reader) *Scanner {
+	return &Scanner{
+		rd:   bufio.NewReader(r),
+		line: 1,
+	}
+}
+
+// Next returns the next game in the input. Parse problems are reported as a
+// *ParseError, after which Next can be called again to continue with the next
+// game. At the end of the input Next returns io.EOF, or the error that
+// occurred reading the input.
+func (s *Scanner) Next() (*Game, error) {
+	for {
+		if s.p != nil {
+			game, err := s.p.readGame()
+			if err != nil || game != nil {
+				return game, err
+			}
+			s.p = nil
+		}
+		text, line := s.chunk()
+		if text == "" {
+			if s.err == nil || s.err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, s.err
+		}
+		s.p = &parser{lex: newLexer(text, line)}
+	}
+}
+
+// chunk reads the text of the next game from the input: the tag section and
+// the movetext up to the next line starting with a '[' (outside of a
+// comment). It returns the text and the line number it starts at.
+func (s *Scanner) chunk() (text string, line int) {
+	var (
+		buf       strings.Builder
+		movetext  bool // seen movetext?
+		inComment bool // inside a {...} comment?
+	)
+	line = s.line
+	for {
+		l := s.pending
+		s.pending = ""
+		if l == "" {
+			if s.err != nil {
+				break
+			}
+			l, s.err = s.rd.ReadString('\n')
+			if l == "" {
+				continue
+			}
+		}
+		trimmed := strings.TrimLeft(l, " \t\r")
+		if !inComment && strings.HasPrefix(trimmed, "[") {
+			if movetext {
+				s.pending = l // tags of the next game
+				break
+			}
+		} else if !strings.HasPrefix(l, "%") {
+			movetext, inComment = scanMovetext(trimmed, movetext, inComment)
+		}
+		buf.WriteString(l)
+		s.line++
+	}
+	return buf.String(), line
+}
+
+// scanMovetext scans a line of movetext to keep track of whether the line ends
+// inside a block comment and whether any movetext (other than comments) has
+// been seen.
+func scanMovetext(l string, movetext, inComment bool) (bool, bool) {
+	for _, r := range l {
+		switch {
+		case inComment:
+			inComment = r != '}'
+		case r == '{':
+			inComment = true
+		case r == ';':
+			return movetext, false
+		case r != ' ' && r != '\t' && r != '\r' && r != '\n':
+			movetext = true
+		}
+	}
+	return movetext, inComment
+}
@@ -0,0 +1,81 @@
+package pgn
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const scanInput = `[Event "first"]
+[Result "*"]
+
+1. e4 e5 {a comment
+[not a tag]} 2. Nf3 *
+
+{ comment before the tags }
+[Event "second"]
+[Result "0-1"]
+
+1. d4 d5 2. c4 0-1
+[Event "third"]
+[Result "1-0"]
+1. f4 1-0
+`
+
+func TestScan(t *testing.T) {
+	want := []struct {
+		tags  ttags
+		plies int
+	}{
+		{ttags{"Event": "first", "Result": "*"}, 3},
+		{ttags{"Event": "second", "Result": "0-1"}, 3},
+		{ttags{"Event": "third", "Result": "1-0"}, 1},
+	}
+	s := Scan(strings.NewReader(scanInput))
+	for i, w := range want {
+		game, err := s.Next()
+		if err != nil {
+			t.Fatalf("game %d: %s", i, err)
+		}
+		if !reflect.DeepEqual(ttags(game.Tags), w.tags) {
+			t.Errorf("game %d: got tags %v, want %v", i, game.Tags, w.tags)
+		}
+		if err := game.ParseMoves(); err != nil {
+			t.Errorf("game %d: %s", i, err)
+		}
+		if plies := game.Plies(); plies != w.plies {
+			t.Errorf("game %d: got %d plies, want %d", i, plies, w.plies)
+		}
+	}
+	if game, err := s.Next(); err != io.EOF {
+		t.Errorf("got %v, %v after last game, want io.EOF", game, err)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	input := "[White \"John\"\n[Result \"*\"]\n\n1. d4 *\n\n[Result \"*\"]\n1. e4 *\n"
+	var errs []string
+	var games int
+	s := Scan(strings.NewReader(input))
+	for {
+		_, err := s.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			games++
+		}
+	}
+	wantErrs := []string{
+		`1:14: expected ']', got '['`,
+		`4:1: no game tags found`,
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Errorf("got errors %q, want %q", errs, wantErrs)
+	}
+	if games != 1 {
+		t.Errorf("got %d games, want 1", games)
+	}
+}
@@ -0,0 +1,258 @@
+package pgn
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// sevenTagRoster lists the tags that are written first, in this order, when
+// exporting a game. They are always written; missing tags get the value that
+// the PGN standard prescribes for unknown values.
+var sevenTagRoster = []struct {
+	name string
+	def  string
+}{
+	{"Event", "?"},
+	{"Site", "?"},
+	{"Date", "????.??.??"},
+	{"Round", "?"},
+	{"White", "?"},
+	{"Black", "?"},
+	{"Result", "*"},
+}
+
+// ExportOptions controls the output of WritePGN.
+type ExportOptions struct {
+	Width        int  // maximum line length (0 means 80)
+	NoComments   bool // leave out comments
+	NoVariations bool // leave out variations
+	NoNags       bool // leave out NAGs
+	Figurines    bool // write moves with figurines instead of piece letters
+}
+
+var defaultExportOptions = ExportOptions{Width: 80}
+
+// String returns the game in PGN format.
+func (g *Game) String() string {
+	var buf strings.Builder
+	g.WriteTo(&buf)
+	return buf.String()
+}
+
+// WriteTo writes the game in PGN format to w: the tags (the Seven Tag Roster
+// first, then the remaining tags in the order of TagOrder, then any others
+// sorted by name), an empty line and the
+// movetext, including comments, NAGs and variations, wrapped at 80 columns.
+// If the movetext of a game read from a PGN file has not been parsed yet, it
+// is parsed first.
+func (g *Game) WriteTo(w io.Writer) (int64, error) {
+	text, err := g.pgn(&defaultExportOptions)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, text)
+	return int64(n), err
+}
+
+// WritePGN is like WriteTo, but the output can be tweaked with opts. If opts
+// is nil, the output is the same as that of WriteTo.
+func (g *Game) WritePGN(w io.Writer, opts *ExportOptions) error {
+	if opts == nil {
+		opts = &defaultExportOptions
+	}
+	text, err := g.pgn(opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, text)
+	return err
+}
+
+// pgn returns the game in PGN format.
+func (g *Game) pgn(opts *ExportOptions) (string, error) {
+	if err := g.ParseMoves(); err != nil {
+		return "", err
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = defaultExportOptions.Width
+	}
+	var buf strings.Builder
+	g.writeTags(&buf)
+	buf.WriteByte('\n')
+	col := 0
+	for _, tok := range g.movetext(opts) {
+		if col > 0 && col+1+len([]rune(tok)) > width {
+			buf.WriteByte('\n')
+			col = 0
+		}
+		if col > 0 {
+			buf.WriteByte(' ')
+			col++
+		}
+		buf.WriteString(tok)
+		col += len([]rune(tok))
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+// writeTags writes the tag pairs of the game.
+func (g *Game) writeTags(buf *strings.Builder) {
+	seen := make(map[string]bool)
+	for _, tag := range sevenTagRoster {
+		val, ok := g.Tags[tag.name]
+		if !ok {
+			val = tag.def
+		}
+		writeTag(buf, tag.name, val)
+		seen[tag.name] = true
+	}
+	for _, name := range g.TagOrder {
+		if val, ok := g.Tags[name]; ok && !seen[name] {
+			writeTag(buf, name, val)
+			seen[name] = true
+		}
+	}
+	var names []string
+	for name := range g.Tags {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeTag(buf, name, g.Tags[name])
+	}
+}
+
+func writeTag(buf *strings.Builder, name, val string) {
+	val = strings.Replace(val, `\`, `\\`, -1)
+	val = strings.Replace(val, `"`, `\"`, -1)
+	fmt.Fprintf(buf, "[%s \"%s\"]\n", name, val)
+}
+
+// movetext returns the movetext of the game as a list of tokens, ending with
+// the game result. Comments are split into words, so that lines can be
+// wrapped inside comments.
+func (g *Game) movetext(opts *ExportOptions) []string {
+	tokens := variationTokens(nil, g.Root, opts)
+	result := g.Tags["Result"]
+	if result == "" {
+		result = "*"
+	}
+	return append(tokens, result)
+}
+
+// variationTokens appends the tokens of the variation starting at root to
+// tokens.
+func variationTokens(tokens []string, root *Node, opts *ExportOptions) []string {
+	tokens = commentTokens(tokens, root.Comment, opts)
+	needNumber := true // black moves need a move number (12...)
+	preceding := true  // comments are read as preceding the next move
+	for n := root.Next; n != nil; n = n.Next {
+		// The move number is part of the move's token so that the
+		// two are never separated by a line break. Preceding
+		// comments are written before the move number, unless they
+		// would be read as following the previous move; then they go
+		// between the number and the move. One move quality NAG (1-6)
+		// can be appended as a suffix to the move (e4!), the others
+		// are written as $<nag>.
+		before := len(n.CommentBefore) > 0 && !opts.NoComments
+		if before && preceding {
+			tokens = commentTokens(tokens, n.CommentBefore, opts)
+			needNumber, before = true, false
+		}
+		b := n.Parent.Board
+		san := n.Move.San(b)
+		if opts.Figurines {
+			san = n.Move.Fan(b)
+		}
+		prefix := ""
+		if number, black := n.MoveNumber(); !black {
+			prefix = fmt.Sprintf("%d.", number)
+		} else if needNumber || before {
+			prefix = fmt.Sprintf("%d...", number)
+		}
+		if before {
+			tokens = append(tokens, prefix)
+			tokens = commentTokens(tokens, n.CommentBefore, opts)
+		} else if prefix != "" {
+			san = prefix + " " + san
+		}
+		suffix := false
+		var nags []string
+		for _, nag := range n.Nags {
+			if opts.NoNags {
+				break
+			}
+			if nag >= 1 && nag <= 6 && !suffix {
+				san += nag.Symbol()
+				suffix = true
+			} else {
+				nags = append(nags, fmt.Sprintf("$%d", nag))
+			}
+		}
+		tokens = append(tokens, san)
+		tokens = append(tokens, nags...)
+		tokens = commentTokens(tokens, n.Comment, opts)
+		needNumber = len(n.Comment) > 0 && !opts.NoComments
+		preceding = false
+		if opts.NoVariations {
+			continue
+		}
+		for _, v := range n.Variations() {
+			start := len(tokens)
+			tokens = variationTokens(tokens, v, opts)
+			tokens[start] = "(" + tokens[start]
+			tokens[len(tokens)-1] += ")"
+			needNumber, preceding = true, true
+		}
+		if v := commentVariation(n); v != nil && !opts.NoComments {
+			start := len(tokens)
+			tokens = commentTokens(tokens, v.Comment, opts)
+			tokens[start] = "(" + tokens[start]
+			tokens[len(tokens)-1] += ")"
+			needNumber, preceding = true, true
+		}
+	}
+	return tokens
+}
+
+// commentVariation returns the empty variation ending the variations of n if
+// it holds comments, as parsed from a variation without moves: ({comment}).
+// Variations does not list it.
+func commentVariation(n *Node) *Node {
+	if n.Parent != nil && n.Parent.IsRoot() && n.Parent.Parent != nil {
+		return nil // the variations were listed for a previous node
+	}
+	v := n.Variation
+	for v != nil && v.Next != nil {
+		v = v.Next.Variation
+	}
+	if v == nil || len(v.Comment) == 0 {
+		return nil
+	}
+	return v
+}
+
+// commentTokens appends the words of each comment paragraph, the first and
+// last word including the braces.
+func commentTokens(tokens []string, comments []string, opts *ExportOptions) []string {
+	if opts.NoComments {
+		return tokens
+	}
+	for _, c := range comments {
+		words := strings.Fields(c)
+		if len(words) == 0 {
+			tokens = append(tokens, "{}")
+			continue
+		}
+		words[0] = "{" + words[0]
+		words[len(words)-1] += "}"
+		tokens = append(tokens, words...)
+	}
+	return tokens
+}
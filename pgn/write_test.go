@@ -0,0 +1,194 @@
+package pgn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func findParseTest(name string) *parseTest {
+	for i := range parseTests {
+		if parseTests[i].name == name {
+			return &parseTests[i]
+		}
+	}
+	panic("no parse test named " + name)
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	names := []string{
+		"annotations",
+		"commented variation",
+		"comment placement",
+		"comment before and after",
+		"comment-only variation",
+		"root node comment",
+		"multiple variations (nested)",
+		"null moves",
+		"with FEN tag",
+	}
+	for _, name := range names {
+		test := findParseTest(name)
+		var db DB
+		if errs := db.Parse(test.input); errs != nil {
+			t.Fatalf("%s: %v", name, errs)
+		}
+		text := db.Games[0].String()
+		games, errors := collectGames(&parseTest{name: name, input: text})
+		if errors != nil {
+			t.Errorf("%s: reparsing\n%s\nfailed: %v", name, text, errors)
+			continue
+		}
+		if !reflect.DeepEqual(games[0].nodes, test.games[0].nodes) {
+			t.Errorf("%s: incorrect game tree after round trip of\n%s", name, text)
+			t.Errorf("got:  %v\n", games[0].nodes)
+			t.Errorf("want: %v\n", test.games[0].nodes)
+		}
+		for tag, val := range test.games[0].tags {
+			if games[0].tags[tag] != val {
+				t.Errorf("%s: tag %s: got %q, want %q", name, tag, games[0].tags[tag], val)
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	input := `[Result "*"] [Annotator "me"] [Event "a\"b"]
+		{start} 1. e4 e5 (1... d5 2. exd5) 2. Nf3 $14 {good} Nc6 *`
+	want := `[Event "a\"b"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "*"]
+[Annotator "me"]
+
+{start} 1. e4 e5 (1... d5 2. exd5) 2. Nf3 $14 {good} 2... Nc6 *
+`
+	var db DB
+	if errs := db.Parse(input); errs != nil {
+		t.Fatal(errs)
+	}
+	if got := db.Games[0].String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTagOrder(t *testing.T) {
+	input := `[WhiteElo "2700"] [White "A"] [Opening "Sicilian"] [ECO "B20"]
+		[Black "B"] [Result "1-0"] [Annotator "me"] 1. e4 c5 1-0`
+	var db DB
+	if errs := db.Parse(input); errs != nil {
+		t.Fatal(errs)
+	}
+	g := db.Games[0]
+	g.Tags["BlackElo"] = "2650" // not from the file: written last
+	want := []string{"Event", "Site", "Date", "Round", "White", "Black", "Result",
+		"WhiteElo", "Opening", "ECO", "Annotator", "BlackElo"}
+	for i := 0; i < 2; i++ {
+		var names []string
+		for _, line := range strings.Split(g.String(), "\n") {
+			if strings.HasPrefix(line, "[") {
+				names = append(names, strings.Fields(line[1:])[0])
+			}
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("pass %d: got tags %v, want %v", i+1, names, want)
+		}
+		// reading the output back preserves the order
+		db = DB{}
+		if errs := db.Parse(g.String()); errs != nil {
+			t.Fatal(errs)
+		}
+		g = db.Games[0]
+	}
+}
+
+func TestWriteFigurines(t *testing.T) {
+	var db DB
+	if errs := db.Parse(operaGame + "\n" + `[Result "*"] 1. e4 d5 2. exd5 c6 3. dxc6 Nf6 4. cxb7 e5 5. bxa8=Q *`); errs != nil {
+		t.Fatal(errs)
+	}
+	for _, game := range db.Games {
+		var buf strings.Builder
+		if err := game.WritePGN(&buf, &ExportOptions{Figurines: true}); err != nil {
+			t.Fatal(err)
+		}
+		text := buf.String()
+		if !strings.Contains(text, "♘") && !strings.Contains(text, "♕") {
+			t.Errorf("no figurines in\n%s", text)
+		}
+		var db2 DB
+		if errs := db2.Parse(text); errs != nil {
+			t.Fatalf("reparsing\n%s\nfailed: %v", text, errs)
+		}
+		g := db2.Games[0]
+		if err := g.ParseMoves(); err != nil {
+			t.Fatalf("reparsing\n%s\nfailed: %v", text, err)
+		}
+		if got, want := g.Moves(), game.Moves(); !reflect.DeepEqual(got, want) {
+			t.Errorf("moves differ after round trip of\n%s", text)
+		}
+	}
+}
+
+const operaGame = `[Event "Paris"] [White "Paul Morphy"] [Black "Duke Karl / Count Isouard"]
+[Result "1-0"]
+1. e4 e5 2. Nf3 d6 {This is the Philidor Defence. It is solid but can be
+passive.} 3. d4 Bg4 $6 {This is a weak move already.} (3... exd4 {is more
+usual}) 4. dxe5 Bxf3 5. Qxf3 dxe5 6. Bc4 Nf6 7. Qb3 Qe7 8. Nc3 c6 9. Bg5 b5
+10. Nxb5 cxb5 11. Bxb5+ Nbd7 12. O-O-O Rd8 13. Rxd7 Rxd7 14. Rd1 Qe6
+15. Bxd7+ Nxd7 16. Qb8+ Nxb8 17. Rd8# 1-0`
+
+func TestWritePGN(t *testing.T) {
+	var db DB
+	if errs := db.Parse(operaGame); errs != nil {
+		t.Fatal(errs)
+	}
+	game := db.Games[0]
+	for _, width := range []int{30, 50, 80} {
+		var buf strings.Builder
+		if err := game.WritePGN(&buf, &ExportOptions{Width: width}); err != nil {
+			t.Fatal(err)
+		}
+		text := buf.String()
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "[") {
+				continue // tags are not wrapped
+			}
+			if n := len([]rune(line)); n > width {
+				t.Errorf("width %d: line of length %d: %q", width, n, line)
+			}
+		}
+		games, errors := collectGames(&parseTest{input: text})
+		if errors != nil {
+			t.Errorf("width %d: reparsing failed: %v", width, errors)
+			continue
+		}
+		want := normaliseComments(collectVariation(game.Root))
+		if !reflect.DeepEqual(normaliseComments(games[0].nodes), want) {
+			t.Errorf("width %d: incorrect game tree after round trip of\n%s", width, text)
+		}
+	}
+
+	var buf strings.Builder
+	opts := &ExportOptions{NoComments: true, NoVariations: true, NoNags: true}
+	if err := game.WritePGN(&buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	text := buf.String()
+	if strings.ContainsAny(text, "{($") {
+		t.Errorf("comments, variations or NAGs found in\n%s", text)
+	}
+}
+
+// normaliseComments replaces all whitespace in comments by single spaces.
+func normaliseComments(nodes []tnode) []tnode {
+	for i := range nodes {
+		nodes[i].before = strings.Join(strings.Fields(nodes[i].before), " ")
+		nodes[i].comment = strings.Join(strings.Fields(nodes[i].comment), " ")
+		nodes[i].variation = normaliseComments(nodes[i].variation)
+	}
+	return nodes
+}
@@ -0,0 +1,55 @@
+package chess
+
+import "math/rand"
+
+// RandomPosition returns a random legal position, for fuzz testing. Besides
+// the two kings it has up to 16 other pieces, without pawns on the first or
+// last rank. The side to move is not checkmated, and castling rights are
+// given (at random) only to kings and rooks on their orthodox starting
+// squares. The position passes Validate.
+func RandomPosition(rng *rand.Rand) *Board {
+	pieces := []Piece{WP, WN, WB, WR, WQ, BP, BN, BB, BR, BQ}
+	for {
+		b := &Board{
+			SideToMove: rng.Intn(2),
+			MoveNr:     1,
+			EpSquare:   NoSquare,
+			CastleSq:   [4]Sq{NoSquare, NoSquare, NoSquare, NoSquare},
+		}
+		// put the kings on their home squares now and then, to
+		// allow castling
+		wk, bk := Sq(rng.Intn(64)), Sq(rng.Intn(64))
+		if rng.Intn(2) == 0 {
+			wk, bk = E1, E8
+		}
+		if wk.Distance(bk) < 2 {
+			continue
+		}
+		b.Piece[wk], b.Piece[bk] = WK, BK
+		for n := rng.Intn(17); n > 0; n-- {
+			sq, p := Sq(rng.Intn(64)), pieces[rng.Intn(len(pieces))]
+			if r := sq.Rank(); b.Piece[sq] != NoPiece ||
+				(p.Type() == Pawn && (r == Rank1 || r == Rank8)) {
+				continue
+			}
+			b.Piece[sq] = p
+		}
+		for _, c := range []struct {
+			i          int
+			king, rook Sq
+		}{
+			{WhiteOO, E1, H1}, {WhiteOOO, E1, A1},
+			{BlackOO, E8, H8}, {BlackOOO, E8, A8},
+		} {
+			color := c.i & 0x01
+			if b.Piece[c.king] == Piece(color|King) &&
+				b.Piece[c.rook] == Piece(color|Rook) && rng.Intn(2) == 0 {
+				b.CastleSq[c.i] = c.rook
+			}
+		}
+		if b.Validate() != nil || b.IsCheckmate() {
+			continue
+		}
+		return b
+	}
+}
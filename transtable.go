@@ -0,0 +1,49 @@
+package chess
+
+// TransTable is a transposition table: a map from positions to values of type
+// T. Positions are looked up by Hash, and hash collisions are resolved by
+// comparing the positions with Equal, so that distinct positions never share
+// an entry. The zero value is an empty table ready for use.
+type TransTable[T any] struct {
+	entries map[uint64][]ttEntry[T]
+}
+
+type ttEntry[T any] struct {
+	board Board
+	value T
+}
+
+// Get returns the value stored for the position b, or !ok if there is none.
+func (t *TransTable[T]) Get(b *Board) (v T, ok bool) {
+	for _, e := range t.entries[b.Hash()] {
+		if e.board.Equal(b) {
+			return e.value, true
+		}
+	}
+	return v, false
+}
+
+// Put stores v for the position b, replacing any value stored before.
+func (t *TransTable[T]) Put(b *Board, v T) {
+	if t.entries == nil {
+		t.entries = make(map[uint64][]ttEntry[T])
+	}
+	key := b.Hash()
+	entries := t.entries[key]
+	for i := range entries {
+		if entries[i].board.Equal(b) {
+			entries[i].value = v
+			return
+		}
+	}
+	t.entries[key] = append(entries, ttEntry[T]{*b, v})
+}
+
+// Len returns the number of positions in the table.
+func (t *TransTable[T]) Len() int {
+	n := 0
+	for _, entries := range t.entries {
+		n += len(entries)
+	}
+	return n
+}